@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/kris-hansen/grappler/internal/cli"
+	"github.com/kris-hansen/grappler/internal/process"
 	"github.com/spf13/cobra"
 )
 
@@ -13,15 +15,55 @@ func main() {
 		Use:   "grappler",
 		Short: "Grappler orchestrates multiple git worktree groups",
 		Long:  `Grappler is a lightweight orchestration tool for running multiple git worktrees (backend + frontend pairs) simultaneously with port isolation.`,
+		// Pick the profile, upgrade files from older versions, then notice
+		// reboots before any command trusts the PIDs in state
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ApplyPaths(cmd, args); err != nil {
+				return err
+			}
+			cli.AutoMigrate(cmd, args)
+			cli.CheckReboot(cmd, args)
+			return nil
+		},
 	}
+	cli.AddPathFlags(rootCmd)
 
 	rootCmd.AddCommand(cli.InitCmd())
+	rootCmd.AddCommand(cli.ImportCmd())
+	rootCmd.AddCommand(cli.ConfigCmd())
+	rootCmd.AddCommand(cli.MigrateCmd())
+	rootCmd.AddCommand(cli.GroupCmd())
+	rootCmd.AddCommand(cli.ServiceCmd())
+	rootCmd.AddCommand(cli.DataCmd())
 	rootCmd.AddCommand(cli.StartCmd())
 	rootCmd.AddCommand(cli.StopCmd())
+	rootCmd.AddCommand(cli.RestartCmd())
+	rootCmd.AddCommand(cli.UpCmd())
 	rootCmd.AddCommand(cli.StatusCmd())
+	rootCmd.AddCommand(cli.SessionCmd())
+	rootCmd.AddCommand(cli.ResumeCmd())
+	rootCmd.AddCommand(cli.SystemdCmd())
+	rootCmd.AddCommand(cli.EnvCmd())
+	rootCmd.AddCommand(cli.ExecCmd())
+	rootCmd.AddCommand(cli.ShellCmd())
+	rootCmd.AddCommand(cli.HookCmd())
+	rootCmd.AddCommand(cli.TmuxCmd())
+	rootCmd.AddCommand(cli.ZellijCmd())
 
 	if err := rootCmd.Execute(); err != nil {
+		// Commands run by exec and shell report their own failures
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		fmt.Fprintln(os.Stderr, err)
+
+		// Propagate a failing hook's exit code
+		var hookErr *process.HookError
+		if errors.As(err, &hookErr) {
+			os.Exit(hookErr.ExitCode)
+		}
 		os.Exit(1)
 	}
 }
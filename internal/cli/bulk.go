@@ -0,0 +1,307 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// bulkOptions holds the flags that select the groups a command acts on
+type bulkOptions struct {
+	all       bool
+	selectors []string
+	parallel  int
+}
+
+// addBulkFlags adds the group selection flags to a command
+func addBulkFlags(cmd *cobra.Command, opts *bulkOptions) {
+	cmd.Flags().BoolVar(&opts.all, "all", false, "Act on every group")
+	cmd.Flags().StringArrayVarP(&opts.selectors, "selector", "l", nil, "Select groups by label: key=value, key!=value or key (repeatable)")
+	cmd.Flags().IntVar(&opts.parallel, "parallel", 1, "Number of groups to act on at once")
+}
+
+// single reports whether args name exactly one group with no patterns or
+// selectors, in which case commands behave as they do for a single group
+func (o *bulkOptions) single(args []string) bool {
+	return !o.all && len(o.selectors) == 0 && len(args) == 1 && !isGlob(args[0])
+}
+
+// selectGroups returns the sorted names matched by args (names or glob
+// patterns), --all and label selectors. Explicit names must be candidates;
+// patterns, --all and selectors only match candidates accepted by eligible.
+func (o *bulkOptions) selectGroups(cfg *config.Config, args, candidates []string, eligible func(name string) bool) ([]string, error) {
+	if len(args) == 0 && !o.all && len(o.selectors) == 0 {
+		return nil, fmt.Errorf("specify groups by name or pattern, or use --all or -l")
+	}
+
+	var requirements []labelRequirement
+	for _, selector := range o.selectors {
+		parsed, err := parseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, parsed...)
+	}
+
+	isCandidate := make(map[string]bool)
+	for _, name := range candidates {
+		isCandidate[name] = true
+	}
+
+	selected := make(map[string]bool)
+	if o.all || len(args) == 0 {
+		for _, name := range candidates {
+			if eligible(name) {
+				selected[name] = true
+			}
+		}
+	}
+
+	for _, arg := range args {
+		if !isGlob(arg) {
+			if !isCandidate[arg] {
+				return nil, fmt.Errorf("group %q not found in config", arg)
+			}
+			selected[arg] = true
+			continue
+		}
+
+		matched := false
+		for _, name := range candidates {
+			ok, err := path.Match(arg, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			if ok && eligible(name) {
+				selected[name] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no groups match %q", arg)
+		}
+	}
+
+	var names []string
+	for name := range selected {
+		var labels map[string]string
+		if group := cfg.Groups[name]; group != nil {
+			labels = group.Labels
+		}
+		if matchLabels(requirements, labels) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no groups matched")
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// isGlob reports whether a group argument is a pattern rather than a name
+func isGlob(arg string) bool {
+	return strings.ContainsAny(arg, "*?[")
+}
+
+// labelRequirement is one term of a label selector
+type labelRequirement struct {
+	key    string
+	value  string
+	negate bool
+	exists bool
+	// me also accepts the current user's name for a value of "me"
+	me string
+}
+
+// parseSelector parses a comma-separated label selector such as
+// "owner=me,team!=web,ticket"
+func parseSelector(selector string) ([]labelRequirement, error) {
+	var requirements []labelRequirement
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req labelRequirement
+		if key, value, ok := strings.Cut(term, "!="); ok {
+			req = labelRequirement{key: key, value: value, negate: true}
+		} else if key, value, ok := strings.Cut(term, "="); ok {
+			req = labelRequirement{key: key, value: value}
+		} else {
+			req = labelRequirement{key: term, exists: true}
+		}
+
+		req.key = strings.TrimSpace(req.key)
+		req.value = strings.TrimSpace(req.value)
+		if req.key == "" {
+			return nil, fmt.Errorf("invalid label selector %q", selector)
+		}
+		if req.value == "me" {
+			req.me = currentUser()
+		}
+		requirements = append(requirements, req)
+	}
+	return requirements, nil
+}
+
+// matches reports whether a set of labels satisfies the requirement
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+	switch {
+	case r.exists:
+		return ok
+	case r.negate:
+		return !ok || (value != r.value && !r.isMe(value))
+	default:
+		return ok && (value == r.value || r.isMe(value))
+	}
+}
+
+func (r labelRequirement) isMe(value string) bool {
+	return r.me != "" && strings.EqualFold(value, r.me)
+}
+
+// currentUser returns the name "me" stands for in label selectors: the
+// login name, which branch prefixes such as krish/ usually are
+func currentUser() string {
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// formatLabels returns labels as sorted key=value pairs
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + labels[key]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// matchLabels reports whether labels satisfy every requirement
+func matchLabels(requirements []labelRequirement, labels map[string]string) bool {
+	for _, req := range requirements {
+		if !req.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// bulkAction acts on one group, writing progress to out, and returns the
+// result and a short detail for the summary
+type bulkAction func(groupName string, out io.Writer) (result, detail string, err error)
+
+// bulkResult is the outcome of a bulk action on one group
+type bulkResult struct {
+	group  string
+	result string
+	detail string
+}
+
+// runBulk runs action for each group, at most parallel at a time, then prints
+// a summary table of the results
+func runBulk(names []string, parallel int, action bulkAction) error {
+	return printBulkSummary(runBulkActions(names, parallel, action))
+}
+
+// runBulkActions runs action for each group, at most parallel at a time.
+// Output of groups run in parallel is buffered and printed as each one
+// finishes so it doesn't interleave.
+func runBulkActions(names []string, parallel int, action bulkAction) []bulkResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]bulkResult, len(names))
+	var printMu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+
+	for i, name := range names {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			var out io.Writer = os.Stdout
+			var buf bytes.Buffer
+			if parallel > 1 {
+				out = &buf
+			}
+
+			result, detail, err := action(name, out)
+			if err != nil {
+				result = "failed"
+				detail, _, _ = strings.Cut(err.Error(), "\n")
+			}
+			results[i] = bulkResult{group: name, result: result, detail: detail}
+
+			printMu.Lock()
+			defer printMu.Unlock()
+			os.Stdout.Write(buf.Bytes())
+			fmt.Println()
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// printBulkSummary prints a table of bulk results, returning an error if any
+// group failed
+func printBulkSummary(results []bulkResult) error {
+	fmt.Println(repeatString("=", 80))
+	fmt.Printf("%-30s %-10s %s\n", "GROUP", "RESULT", "DETAIL")
+	fmt.Println(repeatString("-", 80))
+
+	failed := 0
+	for _, r := range results {
+		if r.result == "failed" {
+			failed++
+		}
+		detail := r.detail
+		if detail == "" {
+			detail = "-"
+		}
+		fmt.Printf("%-30s %-10s %s\n", r.group, r.result, detail)
+	}
+	fmt.Println(repeatString("=", 80))
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d groups failed", failed, len(results))
+	}
+	return nil
+}
+
+// configGroupNames returns the names of the groups in a config
+func configGroupNames(cfg *config.Config) []string {
+	var names []string
+	for name := range cfg.Groups {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configSchemaRepo        bool
+	configRenderInterpolate bool
+)
+
+// ConfigCmd returns the config command
+func ConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Check and inspect the grappler config",
+	}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Check the config and the repo configs it uses for problems",
+		Long: `Checks ~/.grappler/config.yaml, or the given file, for unknown fields, duplicate keys and values of the wrong type, reporting each with its line and column. It then checks that worktree directories exist, every service has a command, port ranges are valid, references name existing services and group names are unique.
+
+The .grappler.yaml of every worktree the config uses is checked as well. Pass a .grappler.yaml to check just that file.`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE:         runConfigValidate,
+	}
+
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the config file",
+		Long:  `Prints the JSON Schema of ~/.grappler/config.yaml, or of .grappler.yaml with --repo, for editors that complete and check YAML against a schema.`,
+		Args:  cobra.NoArgs,
+		RunE:  runConfigSchema,
+	}
+	schemaCmd.Flags().BoolVar(&configSchemaRepo, "repo", false, "Print the schema of .grappler.yaml instead")
+
+	renderCmd := &cobra.Command{
+		Use:   "render <group>",
+		Short: "Print the effective definition of a group",
+		Long: `Prints a group as grappler runs it: merged over the templates it and its services extend, with each service layered over its worktree's .grappler.yaml.
+
+With --interpolate, ${...} references are resolved as well, against the group's ports (provisional ones if it isn't running).`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runConfigRender,
+	}
+	renderCmd.Flags().BoolVar(&configRenderInterpolate, "interpolate", false, "Resolve ${...} references too")
+
+	cmd.AddCommand(validateCmd, schemaCmd, renderCmd)
+	return cmd
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	path := config.GetConfigPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	var problems []config.Problem
+	var summary string
+	if filepath.Base(path) == config.RepoConfigFile {
+		_, err := config.ReadRepoConfig(filepath.Dir(path))
+		if problems, err = validationProblems(err); err != nil {
+			return err
+		}
+		summary = path
+	} else {
+		cfg, err := config.Load(path)
+		if problems, err = validationProblems(err); err != nil {
+			return err
+		}
+		if cfg != nil {
+			problems = cfg.Validate(path)
+			summary = fmt.Sprintf("%s (%d groups)", path, len(cfg.Groups))
+		}
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("✓ %s is valid\n", summary)
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	return fmt.Errorf("%d problem(s) found", len(problems))
+}
+
+// validationProblems returns the problems of a validation error, passing
+// other errors through
+func validationProblems(err error) ([]config.Problem, error) {
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Problems, nil
+	}
+	return nil, err
+}
+
+func runConfigRender(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+
+	var group *config.Group
+	if configRenderInterpolate {
+		resolved, _, running, err := loadGroupRuntime(groupName)
+		if err != nil {
+			return err
+		}
+		if !running {
+			fmt.Fprintf(os.Stderr, "Note: group %q is not running; ports are provisional\n", groupName)
+		}
+		group = resolved
+	} else {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if group = cfg.Groups[groupName]; group == nil {
+			return fmt.Errorf("group %q not found in config", groupName)
+		}
+	}
+
+	data, err := yaml.Marshal(map[string]*config.Group{groupName: group})
+	if err != nil {
+		return fmt.Errorf("failed to marshal group: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+func runConfigSchema(cmd *cobra.Command, args []string) error {
+	schema, err := config.Schema(configSchemaRepo)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(schema)
+	return err
+}
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var dataResetFrom string
+
+// DataCmd returns the data command
+func DataCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "data",
+		Short: "Manage the data directories of groups",
+		Long:  `Each service of a group gets its own data directory, injected as GRAPPLER_DATA_DIR, so worktrees of the same repo don't share databases, uploads or caches.`,
+	}
+
+	pathCmd := &cobra.Command{
+		Use:          "path <group> [service]",
+		Short:        "Print the data directory of a group or one of its services",
+		Args:         cobra.RangeArgs(1, 2),
+		SilenceUsage: true,
+		RunE:         runDataPath,
+	}
+
+	resetCmd := &cobra.Command{
+		Use:   "reset <group> [service...]",
+		Short: "Delete the data of a group, or seed it from another group",
+		Long: `Deletes the data directories of a group's services, or of the services named. They are created and seeded again on the next start.
+
+With --from, the data of another group's services is copied in right away instead.`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE:         runDataReset,
+	}
+	resetCmd.Flags().StringVar(&dataResetFrom, "from", "", "Group to copy the data from")
+
+	cmd.AddCommand(pathCmd, resetCmd)
+	return cmd
+}
+
+func runDataPath(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	group, exists := cfg.Groups[args[0]]
+	if !exists {
+		return fmt.Errorf("group %q not found in config", args[0])
+	}
+	if len(args) == 1 {
+		fmt.Println(filepath.Join(config.GetDataDir(), args[0]))
+		return nil
+	}
+	if group.Service(args[1]) == nil {
+		return fmt.Errorf("group %q has no service %q", args[0], args[1])
+	}
+	fmt.Println(config.DataDir(args[0], args[1]))
+	return nil
+}
+
+func runDataReset(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+
+	cfg, state, err := loadConfigAndState()
+	if err != nil {
+		return err
+	}
+
+	group, exists := cfg.Groups[groupName]
+	if !exists {
+		return fmt.Errorf("group %q not found in config", groupName)
+	}
+	if groupState := state.GetGroup(groupName); groupState != nil && (groupState.Running || groupState.Systemd) {
+		return fmt.Errorf("group %q is running; stop it first (grappler stop %s)", groupName, groupName)
+	}
+
+	services := args[1:]
+	if len(services) == 0 {
+		for _, svc := range group.ServiceList() {
+			services = append(services, svc.Name)
+		}
+	}
+	for _, name := range services {
+		if group.Service(name) == nil {
+			return fmt.Errorf("group %q has no service %q", groupName, name)
+		}
+	}
+
+	if dataResetFrom != "" {
+		if dataResetFrom == groupName {
+			return fmt.Errorf("can't reset group %q from itself", groupName)
+		}
+		if _, exists := cfg.Groups[dataResetFrom]; !exists {
+			return fmt.Errorf("group %q not found in config", dataResetFrom)
+		}
+		if isGroupRunning(state, dataResetFrom) {
+			fmt.Printf("⚠ Group %q is running; its data may change while it is copied\n", dataResetFrom)
+		}
+	}
+
+	for _, name := range services {
+		dir := config.DataDir(groupName, name)
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+
+		if dataResetFrom == "" {
+			fmt.Printf("✓ Reset %s data of group %q\n", name, groupName)
+			continue
+		}
+
+		source := config.DataDir(dataResetFrom, name)
+		if _, err := os.Stat(source); os.IsNotExist(err) {
+			fmt.Printf("⚠ Group %q has no %s data; %s data of group %q reset\n", dataResetFrom, name, name, groupName)
+			continue
+		}
+		if err := seedDataDir(dir, func(tmp string) error { return copyTree(source, tmp) }); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Copied %s data of group %q to group %q\n", name, dataResetFrom, groupName)
+	}
+	return nil
+}
+
+// prepareDataDir creates the data directory of a service of a resolved
+// group if it doesn't exist yet, seeding it from the group named by
+// data.seed_from when that has data, or else from the paths in data.seed
+func prepareDataDir(out io.Writer, vars *config.Vars, group *config.Group, serviceName string) error {
+	svc := vars.Services[serviceName]
+	service := group.Service(serviceName)
+	if svc == nil || service == nil {
+		return nil
+	}
+
+	dir := svc.DataDir
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+
+	data := service.Data
+	if data == nil {
+		data = &config.Data{}
+	}
+
+	return seedDataDir(dir, func(tmp string) error {
+		if data.SeedFrom != "" {
+			source := config.DataDir(data.SeedFrom, serviceName)
+			if _, err := os.Stat(source); err == nil {
+				fmt.Fprintf(out, "  Seeding %s data from group %q\n", serviceName, data.SeedFrom)
+				return copyTree(source, tmp)
+			}
+		}
+
+		for _, seed := range data.Seed {
+			path := config.SeedPath(service.Directory, seed)
+			fmt.Fprintf(out, "  Seeding %s data from %s\n", serviceName, path)
+			if err := copyTree(path, filepath.Join(tmp, filepath.Base(path))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// seedDataDir creates a data directory, filled by seed in a temporary
+// directory first so a failed seed doesn't leave a partial one behind
+func seedDataDir(dir string, seed func(tmp string) error) error {
+	tmp := dir + ".seeding"
+	if err := os.RemoveAll(tmp); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	if err := seed(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("failed to seed %s: %w", dir, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return nil
+}
+
+// removeEphemeralData deletes the data directories of a group's services
+// marked ephemeral
+func removeEphemeralData(out io.Writer, groupName string, group *config.Group) {
+	for _, svc := range group.ServiceList() {
+		if svc.Service.Data == nil || !svc.Service.Data.Ephemeral {
+			continue
+		}
+		if err := os.RemoveAll(config.DataDir(groupName, svc.Name)); err != nil {
+			fmt.Fprintf(out, "⚠ Failed to remove ephemeral %s data: %v\n", svc.Name, err)
+		}
+	}
+	// Drop the group's directory if nothing else is left in it
+	os.Remove(filepath.Join(config.GetDataDir(), groupName))
+}
+
+// dirExists reports whether path is an existing directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// copyTree copies a file or directory tree, keeping file modes and
+// symlinks
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		// Sockets, pipes and devices are left out
+		return nil
+	})
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
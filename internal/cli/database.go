@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/database"
+)
+
+func init() {
+	config.SetDatabaseResolver(databaseURL)
+}
+
+// databaseProvider returns the provider managing a group's database
+func databaseProvider(db *config.DatabaseVars) (database.Provider, error) {
+	if db.Provider == "" {
+		return nil, fmt.Errorf("no provider set (expected %s)", strings.Join(database.Names(), ", "))
+	}
+	return database.New(db.Provider, database.Spec{
+		URL:    db.AdminURL,
+		Schema: db.Schema,
+		Dir:    db.Dir,
+	})
+}
+
+// databaseURL returns the URL services connect to a group's database with
+func databaseURL(db *config.DatabaseVars) (string, error) {
+	provider, err := databaseProvider(db)
+	if err != nil {
+		return "", err
+	}
+	return provider.URL(db.Name)
+}
+
+// provisionDatabase creates the group's database if it has one and it
+// doesn't exist yet, cloning the template when one is set
+func provisionDatabase(out io.Writer, vars *config.Vars) error {
+	db := vars.Database
+	if db == nil {
+		return nil
+	}
+
+	provider, err := databaseProvider(db)
+	if err != nil {
+		return fmt.Errorf("group %q: %w", vars.Group, err)
+	}
+
+	exists, err := provider.Exists(db.Name)
+	if err != nil {
+		return fmt.Errorf("group %q: failed to check database %s: %w", vars.Group, db.Name, err)
+	}
+	if exists {
+		fmt.Fprintf(out, "  Database:      %s\n", db.Name)
+		return nil
+	}
+
+	if err := provider.Create(db.Name, db.Template); err != nil {
+		return fmt.Errorf("group %q: failed to create database %s: %w", vars.Group, db.Name, err)
+	}
+	if db.Template != "" {
+		fmt.Fprintf(out, "  Database:      %s (created from %s)\n", db.Name, db.Template)
+	} else {
+		fmt.Fprintf(out, "  Database:      %s (created)\n", db.Name)
+	}
+	return nil
+}
+
+// dropDatabase drops the database of a group as configured, unless the
+// group keeps it
+func dropDatabase(groupName string, group *config.Group) error {
+	if group.Database == nil || group.Database.Keep {
+		return nil
+	}
+
+	vars, err := config.NewVars(groupName, group, nil)
+	if err != nil {
+		return fmt.Errorf("group %q: %w", groupName, err)
+	}
+	provider, err := databaseProvider(vars.Database)
+	if err != nil {
+		return fmt.Errorf("group %q: %w", groupName, err)
+	}
+	if err := provider.Drop(vars.Database.Name); err != nil {
+		return fmt.Errorf("failed to drop database %s of group %q: %w (keep it with --keep-database)", vars.Database.Name, groupName, err)
+	}
+	fmt.Printf("✓ Dropped database %s\n", vars.Database.Name)
+	return nil
+}
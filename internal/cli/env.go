@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/spf13/cobra"
+)
+
+// Variables the shell integration uses to track what it loaded
+const (
+	activeEnvVar = "GRAPPLER_ACTIVE"
+	loadedEnvVar = "GRAPPLER_LOADED"
+	// savedEnvVar holds the host values of loaded variables that override
+	// them, as a JSON object, to restore on unload
+	savedEnvVar = "GRAPPLER_SAVED"
+)
+
+// envrcMarker identifies .envrc files generated by grappler
+const envrcMarker = "# Generated by grappler"
+
+var (
+	envExplain bool
+	envExport  bool
+	envShell   string
+	envDir     string
+	envEnvrc   bool
+)
+
+// EnvCmd returns the env command
+func EnvCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env <group> [service]",
+		Short: "Show the environment of a service",
+		Long: `Prints the environment variables grappler sets for a service (the group's first service by default).
+
+Variables are layered, lowest precedence first:
+  1. host environment
+  2. env files (group, then service, in the order listed)
+  3. env from the service config
+  4. per-run overrides given with 'grappler start --env'
+  5. variables injected by grappler (ports, discovery variables)
+
+Use --explain to show which source set each variable and what it overrode.
+
+Use --export to print shell statements that load the environment into the
+current shell, e.g. eval "$(grappler env --export main)". With --dir instead
+of a group, the group and service are detected from the worktree containing
+the directory, and variables loaded for a previous worktree are unset; this
+is what 'grappler hook' runs on every directory change.
+
+Use --envrc to write a .envrc into each service directory of the group for
+direnv.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: runEnv,
+	}
+
+	cmd.Flags().BoolVar(&envExplain, "explain", false, "Show which source set each variable")
+	cmd.Flags().BoolVar(&envExport, "export", false, "Print shell statements that export the environment")
+	cmd.Flags().StringVar(&envShell, "shell", "", "Shell syntax for --export: bash, zsh or fish (default from $SHELL)")
+	cmd.Flags().StringVar(&envDir, "dir", "", "Detect the group and service from a worktree directory")
+	cmd.Flags().BoolVar(&envEnvrc, "envrc", false, "Write direnv .envrc files into the group's service directories")
+
+	return cmd
+}
+
+func runEnv(cmd *cobra.Command, args []string) error {
+	if envDir != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("--dir cannot be combined with a group")
+		}
+		return runEnvForDir(envDir)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("requires a group (or --dir)")
+	}
+
+	groupName := args[0]
+
+	group, vars, running, err := loadGroupRuntime(groupName)
+	if err != nil {
+		return err
+	}
+
+	if envEnvrc {
+		return writeEnvrcFiles(group, groupName, args)
+	}
+
+	serviceName, err := selectService(group, groupName, args)
+	if err != nil {
+		return err
+	}
+
+	env, err := serviceEnv(vars, group, serviceName)
+	if err != nil {
+		return err
+	}
+
+	if !running {
+		fmt.Fprintf(os.Stderr, "Note: group %q is not running; ports are provisional\n", groupName)
+	}
+
+	if envExport {
+		fmt.Print(exportStatements(exportShell(), groupName+"/"+serviceName, env))
+		return nil
+	}
+
+	for _, v := range env.Vars() {
+		overridden := env.Overridden(v.Key)
+		if v.Source == process.SourceHost && (!envExplain || len(overridden) == 0) {
+			continue
+		}
+
+		if !envExplain {
+			fmt.Printf("%s=%s\n", v.Key, v.Value)
+			continue
+		}
+
+		value := v.Value
+		if strings.ContainsAny(value, "\n\r") {
+			value = strconv.Quote(value)
+		}
+		fmt.Printf("%-30s %-40s %s", v.Key, value, v.Source)
+		if len(overridden) > 0 {
+			sources := make([]string, 0, len(overridden))
+			for i := len(overridden) - 1; i >= 0; i-- {
+				sources = append(sources, overridden[i].Source)
+			}
+			fmt.Printf(" (overrides %s)", strings.Join(sources, ", "))
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// runEnvForDir prints the statements that switch the shell to the
+// environment of the worktree containing dir. It stays quiet when nothing
+// changes, since the shell hook runs it on every directory change.
+func runEnvForDir(dir string) error {
+	shell := exportShell()
+	active := os.Getenv(activeEnvVar)
+
+	groupName, serviceName := detectService(dir)
+	target := ""
+	if groupName != "" {
+		target = groupName + "/" + serviceName
+	}
+	if target == active {
+		return nil
+	}
+
+	// Unload whatever was loaded for the previous worktree, restoring the
+	// host values it overrode, here too so the new env layers over the host's
+	var saved map[string]string
+	if raw := os.Getenv(savedEnvVar); raw != "" {
+		json.Unmarshal([]byte(raw), &saved)
+	}
+	var b strings.Builder
+	for _, key := range strings.Split(os.Getenv(loadedEnvVar), ":") {
+		if key == "" {
+			continue
+		}
+		if value, ok := saved[key]; ok {
+			b.WriteString(exportStatement(shell, key, value))
+			os.Setenv(key, value)
+		} else {
+			b.WriteString(unsetStatement(shell, key))
+			os.Unsetenv(key)
+		}
+	}
+	if active != "" {
+		b.WriteString(unsetStatement(shell, activeEnvVar))
+		b.WriteString(unsetStatement(shell, loadedEnvVar))
+		b.WriteString(unsetStatement(shell, savedEnvVar))
+	}
+
+	if target != "" {
+		env, err := dirServiceEnv(groupName, serviceName)
+		if err != nil {
+			// Unload the previous worktree anyway; its variables don't
+			// belong here
+			fmt.Print(b.String())
+			return err
+		}
+		b.WriteString(exportStatements(shell, target, env))
+	}
+
+	fmt.Print(b.String())
+	return nil
+}
+
+// dirServiceEnv builds the environment of a service detected by --dir
+func dirServiceEnv(groupName, serviceName string) (*process.Env, error) {
+	group, vars, _, err := loadGroupRuntime(groupName)
+	if err != nil {
+		return nil, err
+	}
+	return serviceEnv(vars, group, serviceName)
+}
+
+// detectService finds the group and service whose worktree contains dir,
+// preferring running groups when a worktree belongs to several
+func detectService(dir string) (string, string) {
+	cfg, err := config.Load(config.GetConfigPath())
+	if err != nil {
+		return "", ""
+	}
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		state = config.NewState()
+	}
+
+	type candidate struct {
+		group, service string
+	}
+	byPath := make(map[string][]candidate)
+	var paths []string
+	for name, group := range cfg.Groups {
+		for _, svc := range resolveDirectories(name, group).ServiceList() {
+			path := filepath.Clean(svc.Service.Directory)
+			if _, ok := byPath[path]; !ok {
+				paths = append(paths, path)
+			}
+			byPath[path] = append(byPath[path], candidate{name, svc.Name})
+		}
+	}
+
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	match := matchWorktree(paths, dir)
+	if match == "" {
+		return "", ""
+	}
+
+	candidates := byPath[match]
+	sort.Slice(candidates, func(i, j int) bool {
+		iRunning := isGroupRunning(state, candidates[i].group)
+		jRunning := isGroupRunning(state, candidates[j].group)
+		if iRunning != jRunning {
+			return iRunning
+		}
+		return candidates[i].group < candidates[j].group
+	})
+
+	return candidates[0].group, candidates[0].service
+}
+
+func isGroupRunning(state *config.State, groupName string) bool {
+	groupState := state.GetGroup(groupName)
+	return groupState != nil && groupState.Running
+}
+
+// writeEnvrcFiles writes a direnv .envrc into each selected service
+// directory that loads the service's environment through grappler
+func writeEnvrcFiles(group *config.Group, groupName string, args []string) error {
+	services := group.ServiceList()
+	if len(args) > 1 {
+		if group.Service(args[1]) == nil {
+			return fmt.Errorf("group %q has no service %q", groupName, args[1])
+		}
+		services = []config.NamedService{{Name: args[1], Service: group.Service(args[1])}}
+	}
+
+	for _, svc := range services {
+		path := filepath.Join(svc.Service.Directory, ".envrc")
+		if data, err := os.ReadFile(path); err == nil && !strings.HasPrefix(string(data), envrcMarker) {
+			return fmt.Errorf("%s already exists and was not generated by grappler; remove it first", path)
+		}
+
+		content := fmt.Sprintf("%s: loads the environment of %s/%s\neval \"$(%s env --export --shell bash %s %s)\"\n",
+			envrcMarker, groupName, svc.Name, shellQuote(grapplerExecutable()), shellQuote(groupName), shellQuote(svc.Name))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Printf("✓ Wrote %s\n", path)
+	}
+
+	fmt.Println("\nRun 'direnv allow' in each directory to enable it")
+	return nil
+}
+
+// exportShell returns the shell syntax to print, from --shell or $SHELL
+func exportShell() string {
+	if envShell != "" {
+		return envShell
+	}
+	switch filepath.Base(os.Getenv("SHELL")) {
+	case "fish":
+		return "fish"
+	case "zsh":
+		return "zsh"
+	}
+	return "bash"
+}
+
+// exportStatements returns statements exporting every variable not taken
+// from the host, plus the bookkeeping the shell hook uses to unload them:
+// the names exported, and the host values of those that override one
+func exportStatements(shell, target string, env *process.Env) string {
+	var b strings.Builder
+	var keys []string
+	saved := make(map[string]string)
+
+	for _, v := range env.Vars() {
+		if v.Source == process.SourceHost {
+			continue
+		}
+		b.WriteString(exportStatement(shell, v.Key, v.Value))
+		keys = append(keys, v.Key)
+		for _, previous := range env.Overridden(v.Key) {
+			if previous.Source == process.SourceHost {
+				saved[v.Key] = previous.Value
+			}
+		}
+	}
+
+	b.WriteString(exportStatement(shell, activeEnvVar, target))
+	b.WriteString(exportStatement(shell, loadedEnvVar, strings.Join(keys, ":")))
+	if len(saved) > 0 {
+		data, _ := json.Marshal(saved)
+		b.WriteString(exportStatement(shell, savedEnvVar, string(data)))
+	} else {
+		b.WriteString(unsetStatement(shell, savedEnvVar))
+	}
+	return b.String()
+}
+
+func exportStatement(shell, key, value string) string {
+	if shell == "fish" {
+		return fmt.Sprintf("set -gx %s %s;\n", key, fishQuote(value))
+	}
+	return fmt.Sprintf("export %s=%s;\n", key, shellQuote(value))
+}
+
+func unsetStatement(shell, key string) string {
+	if shell == "fish" {
+		return fmt.Sprintf("set -e %s;\n", key)
+	}
+	return fmt.Sprintf("unset %s;\n", key)
+}
+
+// shellQuote single-quotes a value for bash and zsh
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote single-quotes a value for fish, which escapes \ and ' inside quotes
+func fishQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// grapplerExecutable returns the path of the running grappler binary
+func grapplerExecutable() string {
+	if path, err := os.Executable(); err == nil {
+		return path
+	}
+	return "grappler"
+}
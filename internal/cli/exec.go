@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// ExitError carries the exit code of a command grappler ran on the user's
+// behalf; the command has already reported its own failure
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExecCmd returns the exec command
+func ExecCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "exec <group> [service] -- <command> [args...]",
+		Short: "Run a command with a service's environment",
+		Long: `Runs a command in a service's directory with exactly the environment the service starts with, including allocated ports and discovery variables. The service defaults to the group's first service.
+
+If the group isn't running, provisional ports are allocated for the command.`,
+		Example: `  grappler exec main -- go run cmd/migrate/main.go up
+  grappler exec dakar-davis frontend -- pnpm test`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runExec,
+	}
+}
+
+// ShellCmd returns the shell command
+func ShellCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "shell <group> [service]",
+		Short: "Open a shell with a service's environment",
+		Long: `Opens $SHELL in a service's directory with exactly the environment the service starts with. The service defaults to the group's first service.
+
+If the group isn't running, provisional ports are allocated for the shell.`,
+		Args:          cobra.RangeArgs(1, 2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runShell,
+	}
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	dash := cmd.ArgsLenAtDash()
+	if dash < 0 || dash == len(args) {
+		return fmt.Errorf("missing command; usage: grappler exec <group> [service] -- <command>")
+	}
+	if dash < 1 || dash > 2 {
+		return fmt.Errorf("expected <group> [service] before --")
+	}
+
+	return runInService(args[:dash], args[dash:])
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	return runInService(args, []string{shell})
+}
+
+// runInService runs argv attached to the terminal in the directory and
+// environment of the service selected by target (<group> [service])
+func runInService(target, argv []string) error {
+	groupName := target[0]
+
+	group, vars, running, err := loadGroupRuntime(groupName)
+	if err != nil {
+		return err
+	}
+
+	serviceName, err := selectService(group, groupName, target)
+	if err != nil {
+		return err
+	}
+
+	env, err := serviceEnv(vars, group, serviceName)
+	if err != nil {
+		return err
+	}
+	if err := prepareDataDir(os.Stderr, vars, group, serviceName); err != nil {
+		return err
+	}
+
+	if !running {
+		fmt.Fprintf(os.Stderr, "Note: group %q is not running; using provisional ports\n", groupName)
+	}
+
+	return runAttached(argv, group.Service(serviceName).Directory, env.List())
+}
+
+// runAttached runs argv in dir with stdio attached. Interrupts are left to
+// the child, and its exit code is returned as an ExitError, 128+signal if
+// a signal killed it.
+func runAttached(argv []string, dir string, env []string) error {
+	c := exec.Command(argv[0], argv[1:]...)
+	c.Dir = dir
+	c.Env = env
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	// The child shares our terminal, so it receives Ctrl-C itself
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// Like a shell, report a child killed by a signal as 128+signal
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				return &ExitError{Code: 128 + int(status.Signal())}
+			}
+			return &ExitError{Code: exitErr.ExitCode()}
+		}
+		return fmt.Errorf("failed to run %s: %w", argv[0], err)
+	}
+
+	return nil
+}
@@ -0,0 +1,462 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+var (
+	groupAddBackend  string
+	groupAddFrontend string
+	groupAddExtends  string
+	groupAddDesc     string
+	groupAddLabels   []string
+	groupRmKeepDB    bool
+)
+
+// GroupCmd returns the group command
+func GroupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "group",
+		Short: "Add, remove, rename and show groups in the config",
+		Long:  `Edits the groups of the config file in place: comments, key order and indentation of the rest of the file are kept.`,
+	}
+
+	addCmd := &cobra.Command{
+		Use:          "add <group>",
+		Short:        "Add a group of worktrees",
+		Long:         `Adds a group running the given worktrees. Branches are read from the worktrees, and labels (owner, ticket, pr) and a description are derived from their names; commands and the rest come from their .grappler.yaml, or can be set with 'grappler service set'.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runGroupAdd,
+	}
+	addCmd.Flags().StringVar(&groupAddBackend, "backend", "", "Backend worktree directory")
+	addCmd.Flags().StringVar(&groupAddFrontend, "frontend", "", "Frontend worktree directory")
+	addCmd.Flags().StringVar(&groupAddExtends, "extends", "", "Group template to layer the group over")
+	addCmd.Flags().StringVar(&groupAddDesc, "description", "", "Description of the group (default: from the branch name)")
+	addCmd.Flags().StringArrayVarP(&groupAddLabels, "label", "l", nil, "Label the group with key=value (repeatable; added to those from the branch name)")
+
+	rmCmd := &cobra.Command{
+		Use:          "rm <group>",
+		Short:        "Remove a group",
+		Long:         `Removes a group from the config, dropping its database unless it sets database.keep or --keep-database is given.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runGroupRm,
+	}
+	rmCmd.Flags().BoolVar(&groupRmKeepDB, "keep-database", false, "Leave the group's database in place")
+
+	renameCmd := &cobra.Command{
+		Use:          "rename <group> <new-name>",
+		Short:        "Rename a group",
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE:         runGroupRename,
+	}
+
+	showCmd := &cobra.Command{
+		Use:          "show <group>",
+		Short:        "Print a group as written in the config",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runGroupShow,
+	}
+
+	labelCmd := &cobra.Command{
+		Use:   "label <group> <key>=<value>|<key>-...",
+		Short: "Set or remove labels of a group",
+		Long: `Sets labels of a group with key=value and removes them with key-, for selecting groups with -l:
+
+  grappler group label main owner=krish ticket=ERE-5326 pr-
+  grappler status -l ticket=ERE-5326`,
+		Args:         cobra.MinimumNArgs(2),
+		SilenceUsage: true,
+		RunE:         runGroupLabel,
+	}
+
+	describeCmd := &cobra.Command{
+		Use:          "describe <group> [description]",
+		Short:        "Set or clear the description of a group",
+		Args:         cobra.RangeArgs(1, 2),
+		SilenceUsage: true,
+		RunE:         runGroupDescribe,
+	}
+
+	cmd.AddCommand(addCmd, rmCmd, renameCmd, showCmd, labelCmd, describeCmd)
+	return cmd
+}
+
+// ServiceCmd returns the service command
+func ServiceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Set and unset fields of a group's services in the config",
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set <group> <service> <key>=<value>...",
+		Short: "Set fields of a service",
+		Long: `Sets fields of a service in the config file, keeping its comments and key order. Keys name a field of the service, with dots for nested fields and map entries:
+
+  grappler service set main backend command="go run ./cmd/api" env.LOG_LEVEL=debug health.path=/healthz
+
+Fields set here override the worktree's .grappler.yaml. Lists such as env_files and hooks are edited in the file.`,
+		Args:         cobra.MinimumNArgs(3),
+		SilenceUsage: true,
+		RunE:         runServiceSet,
+	}
+
+	unsetCmd := &cobra.Command{
+		Use:          "unset <group> <service> <key>...",
+		Short:        "Remove fields of a service",
+		Long:         `Removes fields of a service from the config file, so the worktree's .grappler.yaml applies again. Keys are written as for 'grappler service set'.`,
+		Args:         cobra.MinimumNArgs(3),
+		SilenceUsage: true,
+		RunE:         runServiceUnset,
+	}
+
+	cmd.AddCommand(setCmd, unsetCmd)
+	return cmd
+}
+
+func runGroupAdd(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+	if groupAddBackend == "" && groupAddFrontend == "" && groupAddExtends == "" {
+		return fmt.Errorf("specify the worktrees of the group with --backend and/or --frontend")
+	}
+
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
+	}
+	if doc.HasGroup(groupName) {
+		return fmt.Errorf("group %q already exists", groupName)
+	}
+
+	group := &config.Group{Name: groupName, Extends: groupAddExtends}
+	if group.Backend, err = worktreeService(groupAddBackend); err != nil {
+		return err
+	}
+	if group.Frontend, err = worktreeService(groupAddFrontend); err != nil {
+		return err
+	}
+
+	worktree.DescribeFromBranch(group)
+	if groupAddDesc != "" {
+		group.Description = groupAddDesc
+	}
+	for _, label := range groupAddLabels {
+		key, value, err := parseLabel(label)
+		if err != nil {
+			return err
+		}
+		if group.Labels == nil {
+			group.Labels = make(map[string]string)
+		}
+		group.Labels[key] = value
+	}
+
+	if err := doc.SetGroup(groupName, group); err != nil {
+		return err
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Added group %q\n", groupName)
+	for _, svc := range group.ServiceList() {
+		fmt.Printf("  %s: %s (%s)\n", svc.Name, svc.Service.Directory, svc.Service.Branch)
+	}
+	if len(group.Labels) > 0 {
+		fmt.Printf("  labels: %s\n", formatLabels(group.Labels))
+	}
+	warnGroupProblems(doc, groupName)
+	return nil
+}
+
+// worktreeService returns a service running in a worktree, or nil if dir
+// is empty
+func worktreeService(dir string) (*config.Service, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(absDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("worktree directory %s does not exist", absDir)
+	}
+
+	branch, err := worktree.CurrentBranch(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", absDir, err)
+	}
+	return &config.Service{Directory: absDir, Branch: branch}, nil
+}
+
+func runGroupRm(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+	if err := checkGroupIdle(groupName); err != nil {
+		return err
+	}
+
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
+	}
+	if !doc.HasGroup(groupName) {
+		return fmt.Errorf("group %q not found in config", groupName)
+	}
+
+	// Drop the database first, so a failure leaves the group to try again
+	if !groupRmKeepDB {
+		cfg, err := doc.Config()
+		if err != nil {
+			return fmt.Errorf("%w\n(remove the group with --keep-database to skip its database)", err)
+		}
+		if err := dropDatabase(groupName, cfg.Groups[groupName]); err != nil {
+			return err
+		}
+	}
+
+	if err := doc.RemoveGroup(groupName); err != nil {
+		return err
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Removed group %q\n", groupName)
+	if dataDir := filepath.Join(config.GetDataDir(), groupName); dirExists(dataDir) {
+		fmt.Printf("  Its data is kept in %s\n", dataDir)
+	}
+	return nil
+}
+
+func runGroupRename(cmd *cobra.Command, args []string) error {
+	oldName, newName := args[0], args[1]
+	if err := checkGroupIdle(oldName); err != nil {
+		return err
+	}
+
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
+	}
+	oldDatabase := groupDatabaseName(doc, oldName)
+	if err := doc.RenameGroup(oldName, newName); err != nil {
+		return err
+	}
+
+	// The data directory follows the group, unless the new name already has one
+	oldData := filepath.Join(config.GetDataDir(), oldName)
+	newData := filepath.Join(config.GetDataDir(), newName)
+	moveData := dirExists(oldData)
+	if moveData && dirExists(newData) {
+		return fmt.Errorf("data directory %s already exists; remove it first", newData)
+	}
+	if moveData {
+		if err := os.Rename(oldData, newData); err != nil {
+			return fmt.Errorf("failed to move the group's data: %w", err)
+		}
+	}
+
+	if err := doc.Save(); err != nil {
+		if moveData {
+			os.Rename(newData, oldData)
+		}
+		return err
+	}
+
+	fmt.Printf("✓ Renamed group %q to %q\n", oldName, newName)
+	if newDatabase := groupDatabaseName(doc, newName); oldDatabase != "" && newDatabase != oldDatabase {
+		fmt.Printf("⚠ The group now uses database %s; %s was left in place\n", newDatabase, oldDatabase)
+	}
+	warnGroupProblems(doc, newName)
+	return nil
+}
+
+// groupDatabaseName returns the name of a group's database, or "" if it
+// has none or it can't be resolved
+func groupDatabaseName(doc *config.Document, groupName string) string {
+	cfg, err := doc.Config()
+	if err != nil || cfg.Groups[groupName] == nil {
+		return ""
+	}
+	vars, err := config.NewVars(groupName, cfg.Groups[groupName], nil)
+	if err != nil || vars.Database == nil {
+		return ""
+	}
+	return vars.Database.Name
+}
+
+func runGroupShow(cmd *cobra.Command, args []string) error {
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
+	}
+
+	data, err := doc.GroupYAML(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+func runGroupLabel(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range args[1:] {
+		if key, ok := strings.CutSuffix(arg, "-"); ok && !strings.Contains(arg, "=") {
+			err = doc.UnsetLabel(groupName, key)
+		} else {
+			var value string
+			if key, value, err = parseLabel(arg); err == nil {
+				err = doc.SetLabel(groupName, key, value)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Labeled group %q: %s\n", groupName, strings.Join(args[1:], " "))
+	return nil
+}
+
+func runGroupDescribe(cmd *cobra.Command, args []string) error {
+	groupName, description := args[0], ""
+	if len(args) > 1 {
+		description = args[1]
+	}
+
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
+	}
+	if err := doc.SetDescription(groupName, description); err != nil {
+		return err
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+
+	if description == "" {
+		fmt.Printf("✓ Cleared the description of group %q\n", groupName)
+	} else {
+		fmt.Printf("✓ Described group %q: %s\n", groupName, description)
+	}
+	return nil
+}
+
+// parseLabel parses a key=value label
+func parseLabel(label string) (string, string, error) {
+	key, value, ok := strings.Cut(label, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || strings.ContainsAny(key, ",!") {
+		return "", "", fmt.Errorf("invalid label %q: expected <key>=<value>", label)
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+func runServiceSet(cmd *cobra.Command, args []string) error {
+	groupName, serviceName := args[0], args[1]
+
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range args[2:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("invalid assignment %q: expected <key>=<value>", arg)
+		}
+		if err := doc.SetServiceValue(groupName, serviceName, key, value); err != nil {
+			return err
+		}
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+
+	for _, arg := range args[2:] {
+		fmt.Printf("✓ Set %s of %s/%s\n", arg, groupName, serviceName)
+	}
+	warnGroupProblems(doc, groupName)
+	return nil
+}
+
+func runServiceUnset(cmd *cobra.Command, args []string) error {
+	groupName, serviceName := args[0], args[1]
+
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
+	}
+
+	for _, key := range args[2:] {
+		if err := doc.UnsetServiceValue(groupName, serviceName, key); err != nil {
+			return err
+		}
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Unset %s of %s/%s\n", strings.Join(args[2:], ", "), groupName, serviceName)
+	warnGroupProblems(doc, groupName)
+	return nil
+}
+
+// warnGroupProblems reports the problems of a group after an edit, for
+// 'grappler config validate' to find otherwise. The edit is saved anyway.
+func warnGroupProblems(doc *config.Document, groupName string) {
+	cfg, err := doc.Config()
+	if err != nil {
+		fmt.Printf("⚠ %v\n", err)
+		return
+	}
+
+	prefix := "groups." + groupName
+	for _, problem := range cfg.Validate(config.GetConfigPath()) {
+		if problem.Path == prefix || strings.HasPrefix(problem.Path, prefix+".") {
+			fmt.Printf("⚠ %s\n", problem)
+		}
+	}
+}
+
+// checkGroupIdle refuses to change a group that is running or holds ports,
+// since its processes and state are tracked by name
+func checkGroupIdle(groupName string) error {
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	groupState := state.GetGroup(groupName)
+	if groupState != nil && groupState.Systemd {
+		return fmt.Errorf("group %q is installed as systemd units; remove them first (grappler systemd uninstall %s)", groupName, groupName)
+	}
+	if groupState != nil {
+		return fmt.Errorf("group %q is running or has reserved ports; stop it first (grappler stop %s)", groupName, groupName)
+	}
+	return nil
+}
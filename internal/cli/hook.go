@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// HookCmd returns the hook command
+func HookCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "hook <bash|zsh|fish>",
+		Short: "Print the shell hook that loads group environments on cd",
+		Long: `Prints a shell hook that loads the environment of the group whose worktree you are in, and unloads it when you leave. Add it to your shell's rc file:
+
+  bash: eval "$(grappler hook bash)"      (~/.bashrc)
+  zsh:  eval "$(grappler hook zsh)"       (~/.zshrc)
+  fish: grappler hook fish | source       (~/.config/fish/config.fish)`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		RunE:      runHook,
+	}
+}
+
+func runHook(cmd *cobra.Command, args []string) error {
+	exe := shellQuote(grapplerExecutable())
+
+	switch args[0] {
+	case "bash":
+		fmt.Printf(`_grappler_hook() {
+  local previous_exit_status=$?
+  if [[ "$PWD" != "$_GRAPPLER_PWD" ]]; then
+    _GRAPPLER_PWD="$PWD"
+    eval "$(%s env --export --shell bash --dir "$PWD")"
+  fi
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_grappler_hook;"* ]]; then
+  PROMPT_COMMAND="_grappler_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`, exe)
+	case "zsh":
+		fmt.Printf(`_grappler_hook() {
+  eval "$(%s env --export --shell zsh --dir "$PWD")"
+}
+typeset -ag chpwd_functions
+if (( ! ${chpwd_functions[(I)_grappler_hook]} )); then
+  chpwd_functions=(_grappler_hook $chpwd_functions)
+fi
+_grappler_hook
+`, exe)
+	case "fish":
+		fmt.Printf(`function __grappler_hook --on-variable PWD
+    %s env --export --shell fish --dir "$PWD" | source
+end
+__grappler_hook
+`, fishQuote(grapplerExecutable()))
+	default:
+		return fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", args[0])
+	}
+
+	return nil
+}
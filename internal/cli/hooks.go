@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+)
+
+// groupHooksLog is the log name used for group-level hook output
+const groupHooksLog = "hooks"
+
+// hookRunner runs the lifecycle hooks of a group and its services
+type hookRunner struct {
+	procMgr    *process.Manager
+	state      *config.State
+	group      *config.Group
+	groupName  string
+	forceSetup bool
+	out        io.Writer
+}
+
+// runGroup runs group-level hooks for a stage with the group env
+func (h *hookRunner) runGroup(stage string, env []string) error {
+	commands := h.group.Hooks.Commands(stage)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	if stage == config.HookSetup {
+		return h.runSetup("group", "group:"+h.groupName, commands, h.group.Directory(), process.HookShell(nil), groupHooksLog, env)
+	}
+
+	fmt.Fprintf(h.out, "Running group %s hooks...\n", stage)
+	return h.procMgr.RunHooks(stage, commands, h.group.Directory(), process.HookShell(nil), h.groupName, groupHooksLog, env)
+}
+
+// runService runs the hooks of a service for a stage with its env
+func (h *hookRunner) runService(stage string, service *config.Service, serviceName string, env []string) error {
+	if service == nil {
+		return nil
+	}
+
+	commands := service.Hooks.Commands(stage)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	if stage == config.HookSetup {
+		return h.runSetup(serviceName, service.Directory, commands, service.Directory, process.HookShell(service), serviceName, env)
+	}
+
+	fmt.Fprintf(h.out, "Running %s %s hooks...\n", serviceName, stage)
+	return h.procMgr.RunHooks(stage, commands, service.Directory, process.HookShell(service), h.groupName, serviceName, env)
+}
+
+// runSetup runs setup hooks unless the same commands already completed for
+// key, recording a marker in state on success
+func (h *hookRunner) runSetup(label, key string, commands []string, dir, shell, logName string, env []string) error {
+	hash := hashCommands(commands)
+	if !h.forceSetup && h.state.SetupMarker(key) == hash {
+		return nil
+	}
+
+	fmt.Fprintf(h.out, "Running %s setup hooks in %s...\n", label, dir)
+	if err := h.procMgr.RunHooks(config.HookSetup, commands, dir, shell, h.groupName, logName, env); err != nil {
+		return err
+	}
+
+	h.state.MarkSetup(key, hash)
+	if err := h.state.Save(config.GetStatePath()); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return nil
+}
+
+// hashCommands returns a stable hash of a list of hook commands
+func hashCommands(commands []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(commands, "\n")))
+	return hex.EncodeToString(sum[:])
+}
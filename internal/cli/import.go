@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/importer"
+	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	importGroup    string
+	importBackend  string
+	importFrontend string
+	importDryRun   bool
+)
+
+// ImportCmd returns the import command
+func ImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Create service definitions from a Procfile or docker-compose file",
+		Long: `Translates the processes of a Procfile or docker-compose file into the backend and frontend services of a group: commands, environment, env files, dependencies, health checks and ports. Anything that can't be mapped is reported.
+
+The group is created if it doesn't exist. Services of an existing group keep their directory and branch; the imported command replaces theirs and the imported env is merged into theirs.`,
+	}
+
+	procfileCmd := &cobra.Command{
+		Use:          "procfile <path>",
+		Short:        "Import the processes of a Procfile",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(args[0], importer.ReadProcfile)
+		},
+	}
+
+	composeCmd := &cobra.Command{
+		Use:          "compose <path>",
+		Short:        "Import the services of a docker-compose file",
+		Long:         `Imports the compose services that set a command; they run directly on the host. Services that only run an image, such as databases, are started with 'docker compose up -d' from a group pre_start hook when an imported service depends on them.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(args[0], importer.ReadCompose)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&importGroup, "group", "g", "", "Group to import into (required)")
+	cmd.PersistentFlags().StringVar(&importBackend, "backend", "", "Process to use as the backend (guessed from the names by default)")
+	cmd.PersistentFlags().StringVar(&importFrontend, "frontend", "", "Process to use as the frontend (guessed from the names by default)")
+	cmd.PersistentFlags().BoolVar(&importDryRun, "dry-run", false, "Print the resulting group instead of saving it")
+	cmd.MarkPersistentFlagRequired("group")
+
+	cmd.AddCommand(procfileCmd, composeCmd)
+	return cmd
+}
+
+func runImport(path string, read func(string) (*importer.Result, error)) error {
+	result, err := read(path)
+	if err != nil {
+		return err
+	}
+
+	slots, err := result.AssignSlots(importBackend, importFrontend)
+	if err != nil {
+		return err
+	}
+	if len(slots) == 0 {
+		return fmt.Errorf("nothing to import from %s:\n  %s", path, strings.Join(result.Warnings, "\n  "))
+	}
+	services := result.Services(slots)
+
+	configPath := config.GetConfigPath()
+	cfg, err := config.LoadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		cfg, err = &config.Config{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if cfg.Groups == nil {
+		cfg.Groups = make(map[string]*config.Group)
+	}
+
+	group, exists := cfg.Groups[importGroup]
+	if !exists {
+		group = &config.Group{Name: importGroup}
+		cfg.Groups[importGroup] = group
+	}
+
+	fmt.Printf("Importing %s into group %q\n\n", path, importGroup)
+	for _, name := range []string{"backend", "frontend"} {
+		imported := services[name]
+		if imported == nil {
+			continue
+		}
+		if existing := group.Service(name); existing != nil {
+			imported = mergeImported(existing, imported)
+		}
+		group.SetService(name, imported)
+		fmt.Printf("✓ %s → %s: %s\n", slots[name], name, imported.Command)
+	}
+
+	// Image-only dependencies keep running in docker
+	if deps := result.ImageDependencies(slots); len(deps) > 0 {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		hook := fmt.Sprintf("docker compose -f %s up -d %s", process.QuoteArgv([]string{absPath}), process.QuoteArgv(deps))
+		if group.Hooks == nil {
+			group.Hooks = &config.Hooks{}
+		}
+		if !slices.Contains(group.Hooks.PreStart, hook) {
+			group.Hooks.PreStart = append(group.Hooks.PreStart, hook)
+		}
+		fmt.Printf("✓ %s → group pre_start hook: %s\n", strings.Join(deps, ", "), hook)
+	}
+
+	if len(result.Warnings) > 0 {
+		fmt.Println("\nNot mapped:")
+		for _, warning := range result.Warnings {
+			fmt.Printf("  ⚠ %s\n", warning)
+		}
+	}
+
+	if importDryRun {
+		data, err := yaml.Marshal(map[string]*config.Group{importGroup: group})
+		if err != nil {
+			return fmt.Errorf("failed to marshal group: %w", err)
+		}
+		fmt.Printf("\n%s", data)
+		return nil
+	}
+
+	// Only the imported group is rewritten; the rest of the file is kept as is
+	doc, err := config.LoadDocument(configPath)
+	if err != nil {
+		return err
+	}
+	if err := doc.SetGroup(importGroup, group); err != nil {
+		return err
+	}
+	if err := doc.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	verb := "Updated"
+	if !exists {
+		verb = "Created"
+	}
+	fmt.Printf("\n✓ %s group %q in %s\n", verb, importGroup, configPath)
+	return nil
+}
+
+// mergeImported applies an imported service to an existing one, keeping its
+// directory and branch. The imported command replaces the existing one and
+// the rest is added to what the service already has.
+func mergeImported(existing, imported *config.Service) *config.Service {
+	merged := *existing
+	merged.Command = imported.Command
+	merged.Shell = imported.Shell
+	merged.EnvFiles = append(append([]config.EnvFile(nil), existing.EnvFiles...), imported.EnvFiles...)
+
+	if len(imported.Env) > 0 {
+		merged.Env = make(map[string]string, len(existing.Env)+len(imported.Env))
+		for key, value := range existing.Env {
+			merged.Env[key] = value
+		}
+		for key, value := range imported.Env {
+			merged.Env[key] = value
+		}
+	}
+
+	if imported.Hooks != nil {
+		hooks := config.Hooks{}
+		if existing.Hooks != nil {
+			hooks = *existing.Hooks
+		}
+		hooks.PostStart = append(append([]string(nil), hooks.PostStart...), imported.Hooks.PostStart...)
+		merged.Hooks = &hooks
+	}
+
+	return &merged
+}
@@ -2,6 +2,8 @@ package cli
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/kris-hansen/grappler/internal/config"
 	"github.com/kris-hansen/grappler/internal/worktree"
@@ -13,9 +15,11 @@ func InitCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "init <backend-repo> <frontend-repo>",
 		Short: "Initialize grappler configuration by scanning worktrees",
-		Long:  `Scans the specified backend and frontend repositories for git worktrees and generates a configuration file.`,
-		Args:  cobra.ExactArgs(2),
-		RunE:  runInit,
+		Long: `Scans the specified backend and frontend repositories for git worktrees and generates a configuration file.
+
+Service commands, env, hooks and health checks come from the .grappler.yaml checked into each worktree, so every branch runs with its own definition. The generated config only records the worktrees; anything set there overrides the repo's definition.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runInit,
 	}
 }
 
@@ -46,8 +50,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	// Create config
 	cfg := &config.Config{
-		Version: "1",
-		Groups:  groups,
+		Groups: groups,
 		Proxy: &config.ProxyConfig{
 			Enabled:              true,
 			UseExistingConductor: true,
@@ -71,16 +74,30 @@ func runInit(cmd *cobra.Command, args []string) error {
 	fmt.Printf("✓ State file created at %s\n", statePath)
 	fmt.Printf("\nDiscovered groups:\n")
 
+	var missing []string
 	for name, group := range groups {
 		fmt.Printf("  %s:\n", name)
-		if group.Backend != nil {
-			fmt.Printf("    Backend:  %s (%s)\n", group.Backend.Directory, group.Backend.Branch)
-		}
-		if group.Frontend != nil {
-			fmt.Printf("    Frontend: %s (%s)\n", group.Frontend.Directory, group.Frontend.Branch)
+		for _, svc := range group.ServiceList() {
+			fmt.Printf("    %-9s %s (%s)\n", serviceTitle(svc.Name)+":", svc.Service.Directory, svc.Service.Branch)
+
+			// Commands come from the repo config checked into each worktree
+			repo, err := config.ReadRepoConfig(svc.Service.Directory)
+			if err != nil {
+				fmt.Printf("      ⚠ %v\n", err)
+			} else if repo == nil || repo.ServiceFor(svc.Name).Command.IsZero() {
+				missing = append(missing, fmt.Sprintf("%s.%s", name, svc.Name))
+			} else {
+				fmt.Printf("      %s (from %s)\n", repo.ServiceFor(svc.Name).Command, config.RepoConfigFile)
+			}
 		}
 	}
 
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		fmt.Printf("\n⚠ No command for %s\n", strings.Join(missing, ", "))
+		fmt.Printf("  Check a %s into the repo, or set the command in %s\n", config.RepoConfigFile, configPath)
+	}
+
 	fmt.Printf("\nRun 'grappler start <group>' to start a group\n")
 
 	return nil
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var migrateDryRun bool
+
+// MigrateCmd returns the migrate command
+func MigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade the config and state files to this version of grappler",
+		Long: `Config and state files record the version of the format they were written in. Files written by an older grappler are upgraded by applying each migration in order; the original is kept next to it as <file>.v<version>.bak. Files written by a newer grappler are refused rather than misread.
+
+Every command migrates the files on its own before running; 'grappler migrate --dry-run' shows what would change first.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE:         runMigrate,
+	}
+
+	cmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show the pending migrations without applying them")
+
+	return cmd
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	plans, err := migrationPlans()
+	if err != nil {
+		return err
+	}
+
+	if len(plans) == 0 {
+		fmt.Printf("✓ Config (version %d) and state (version %d) are up to date\n", config.ConfigVersion, config.StateVersion)
+		return nil
+	}
+
+	for _, plan := range plans {
+		fmt.Printf("%s: version %d → %d\n", plan.Path, plan.From, plan.To)
+		for _, step := range plan.Steps {
+			fmt.Printf("  %s\n", step)
+		}
+
+		if migrateDryRun {
+			fmt.Printf("  would back up the original to %s\n", plan.Backup())
+			continue
+		}
+		if err := plan.Apply(); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Migrated, original kept in %s\n", plan.Backup())
+	}
+
+	return nil
+}
+
+// AutoMigrate upgrades files written by an older grappler before a command
+// runs. Files it can't read are left to the command to report.
+func AutoMigrate(cmd *cobra.Command, args []string) {
+	if cmd.Name() == "migrate" {
+		return
+	}
+
+	plans, err := migrationPlans()
+	if err != nil {
+		return
+	}
+	for _, plan := range plans {
+		if err := plan.Apply(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ Failed to migrate %s: %v\n", plan.Path, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "✓ Migrated %s to version %d, original kept in %s\n", plan.Path, plan.To, plan.Backup())
+	}
+}
+
+// migrationPlans returns the migrations pending for the config and state
+func migrationPlans() ([]*config.MigrationPlan, error) {
+	var plans []*config.MigrationPlan
+
+	plan, err := config.PlanConfigMigration(config.GetConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	if plan != nil {
+		plans = append(plans, plan)
+	}
+
+	plan, err = config.PlanStateMigration(config.GetStatePath())
+	if err != nil {
+		return nil, err
+	}
+	if plan != nil {
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
@@ -0,0 +1,292 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/spf13/cobra"
+)
+
+var (
+	muxForeground bool
+	muxDetach     bool
+)
+
+// TmuxCmd returns the tmux command
+func TmuxCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tmux <group>",
+		Short: "Open a tmux session for a group",
+		Long: `Creates (or attaches to) a tmux session named after the group, with one window per service and a shell window per worktree with the group environment loaded.
+
+By default the group is started if needed and service windows tail the service logs. With --foreground, the group is set up as by 'grappler start', reserving its ports and running its sidecars and start hooks, but its services run in their windows instead. 'grappler stop' kills the session and releases the group.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runTmux,
+	}
+
+	cmd.Flags().BoolVar(&muxForeground, "foreground", false, "Run services in the foreground in their windows instead of tailing logs")
+	cmd.Flags().BoolVarP(&muxDetach, "detach", "d", false, "Create the session without attaching to it")
+
+	return cmd
+}
+
+// ZellijCmd returns the zellij command
+func ZellijCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "zellij <group>",
+		Short: "Open a zellij session for a group",
+		Long: `Generates a zellij layout for the group, equivalent to 'grappler tmux', and opens (or attaches to) a zellij session named after the group.
+
+The layout is written to ~/.grappler/run/<group>.kdl; use --detach to only write it.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runZellij,
+	}
+
+	cmd.Flags().BoolVar(&muxForeground, "foreground", false, "Run services in the foreground in their tabs instead of tailing logs")
+	cmd.Flags().BoolVarP(&muxDetach, "detach", "d", false, "Only write the layout file")
+
+	return cmd
+}
+
+// muxWindow is a window (tmux) or tab (zellij) running a shell command
+type muxWindow struct {
+	Name    string
+	Dir     string
+	Command string
+}
+
+// planSession builds the windows of a group session in mux: one per
+// service, then a shell per distinct worktree. Each window sources a
+// generated env script so every window sees the same ports.
+func planSession(groupName, mux string) ([]muxWindow, error) {
+	group, vars, running, err := loadGroupRuntime(groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	if running && muxForeground {
+		return nil, fmt.Errorf("group %q is already running; stop it to run its services in the foreground", groupName)
+	}
+	if !running {
+		opts := startOptions{}
+		if muxForeground {
+			opts.session = mux
+		}
+		if err := startGroup(groupName, opts); err != nil {
+			return nil, err
+		}
+		if group, vars, _, err = loadGroupRuntime(groupName); err != nil {
+			return nil, err
+		}
+	}
+
+	procMgr := process.NewManager(config.GetLogsDir())
+	var windows []muxWindow
+	var shells []muxWindow
+	seenDirs := make(map[string]bool)
+
+	for _, svc := range group.ServiceList() {
+		env, err := serviceEnv(vars, group, svc.Name)
+		if err != nil {
+			return nil, err
+		}
+		argv, runEnv, err := process.ResolveCommand(svc.Service, env.List())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", svc.Name, err)
+		}
+
+		script := filepath.Join(config.GetRunDir(), fmt.Sprintf("%s-%s.env", groupName, svc.Name))
+		if err := writeEnvScript(script, groupName+"/"+svc.Name, runEnv); err != nil {
+			return nil, err
+		}
+
+		command := fmt.Sprintf("tail -n 200 -F %s", shellQuote(procMgr.LogPath(groupName, svc.Name)))
+		if muxForeground {
+			command = fmt.Sprintf(". %s && exec %s", shellQuote(script), process.QuoteArgv(argv))
+		}
+		windows = append(windows, muxWindow{Name: svc.Name, Dir: svc.Service.Directory, Command: command})
+
+		if !seenDirs[svc.Service.Directory] {
+			seenDirs[svc.Service.Directory] = true
+			shells = append(shells, muxWindow{
+				Name:    "shell:" + filepath.Base(svc.Service.Directory),
+				Dir:     svc.Service.Directory,
+				Command: fmt.Sprintf(`. %s && exec "${SHELL:-sh}"`, shellQuote(script)),
+			})
+		}
+	}
+
+	return append(windows, shells...), nil
+}
+
+// writeEnvScript writes a script exporting every variable in env that
+// differs from the host environment
+func writeEnvScript(path, target string, env []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by grappler: environment of %s\n", target)
+	for _, entry := range env {
+		key, value, _ := strings.Cut(entry, "=")
+		if host, ok := os.LookupEnv(key); ok && host == value {
+			continue
+		}
+		b.WriteString(exportStatement("bash", key, value))
+	}
+	b.WriteString(exportStatement("bash", activeEnvVar, target))
+
+	// Env scripts may hold secrets from env files
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write env script: %w", err)
+	}
+	return nil
+}
+
+// killSession kills the multiplexer session running a group's services in
+// the foreground, if it is still open
+func killSession(out io.Writer, mux, groupName string) {
+	session := sessionName(groupName)
+	args := []string{"tmux", "kill-session", "-t", "=" + session}
+	if mux == "zellij" {
+		args = []string{"zellij", "kill-session", session}
+	} else if exec.Command("tmux", "has-session", "-t", "="+session).Run() != nil {
+		return
+	}
+
+	fmt.Fprintf(out, "Killing %s session %q...\n", mux, session)
+	if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		fmt.Fprintf(out, "⚠ Failed to kill %s session %q: %v: %s\n", mux, session, err, strings.TrimSpace(string(output)))
+	}
+}
+
+// sessionName returns a multiplexer-safe session name for a group
+func sessionName(groupName string) string {
+	return strings.NewReplacer(".", "-", ":", "-").Replace(profileQualified(groupName))
+}
+
+func runTmux(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+	session := sessionName(groupName)
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return fmt.Errorf("tmux not found in PATH")
+	}
+
+	if exec.Command("tmux", "has-session", "-t", "="+session).Run() != nil {
+		windows, err := planSession(groupName, "tmux")
+		if err != nil {
+			return err
+		}
+
+		for i, w := range windows {
+			tmuxArgs := []string{"new-window", "-t", session, "-n", w.Name, "-c", w.Dir, w.Command}
+			if i == 0 {
+				tmuxArgs = []string{"new-session", "-d", "-s", session, "-n", w.Name, "-c", w.Dir, w.Command}
+			}
+			if output, err := exec.Command("tmux", tmuxArgs...).CombinedOutput(); err != nil {
+				return fmt.Errorf("tmux %s failed: %v: %s", tmuxArgs[0], err, strings.TrimSpace(string(output)))
+			}
+		}
+		exec.Command("tmux", "select-window", "-t", session+":^").Run()
+
+		fmt.Printf("✓ Created tmux session %q\n", session)
+	}
+
+	if muxDetach {
+		fmt.Printf("Attach with: tmux attach -t %s\n", session)
+		return nil
+	}
+
+	if os.Getenv("TMUX") != "" {
+		return exec.Command("tmux", "switch-client", "-t", session).Run()
+	}
+	return runAttached([]string{"tmux", "attach-session", "-t", session}, "", os.Environ())
+}
+
+func runZellij(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+	session := sessionName(groupName)
+
+	// A live session already runs the group; attach to it as it is
+	if zellijHasSession(session) {
+		if muxDetach {
+			fmt.Printf("Session %q is open; attach with: zellij attach %s\n", session, session)
+			return nil
+		}
+		return runAttached([]string{"zellij", "attach", session}, "", os.Environ())
+	}
+
+	windows, err := planSession(groupName, "zellij")
+	if err != nil {
+		return err
+	}
+
+	layoutPath := filepath.Join(config.GetRunDir(), groupName+".kdl")
+	if err := os.WriteFile(layoutPath, []byte(zellijLayout(windows)), 0644); err != nil {
+		return fmt.Errorf("failed to write zellij layout: %w", err)
+	}
+	fmt.Printf("✓ Wrote zellij layout to %s\n", layoutPath)
+
+	if muxDetach {
+		fmt.Printf("Open with: zellij --session %s --layout %s\n", session, layoutPath)
+		return nil
+	}
+
+	if _, err := exec.LookPath("zellij"); err != nil {
+		return fmt.Errorf("zellij not found in PATH")
+	}
+
+	return runAttached([]string{"zellij", "--session", session, "--layout", layoutPath}, "", os.Environ())
+}
+
+// zellijHasSession reports whether a zellij session is open, which it
+// never is without zellij installed
+func zellijHasSession(session string) bool {
+	output, err := exec.Command("zellij", "list-sessions", "--short").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == session {
+			return true
+		}
+	}
+	return false
+}
+
+// zellijLayout renders session windows as a zellij KDL layout with one tab
+// per window
+func zellijLayout(windows []muxWindow) string {
+	var b strings.Builder
+	b.WriteString("// Generated by grappler\nlayout {\n")
+	b.WriteString("    default_tab_template {\n")
+	b.WriteString("        pane size=1 borderless=true {\n            plugin location=\"zellij:tab-bar\"\n        }\n")
+	b.WriteString("        children\n")
+	b.WriteString("        pane size=2 borderless=true {\n            plugin location=\"zellij:status-bar\"\n        }\n")
+	b.WriteString("    }\n")
+
+	for _, w := range windows {
+		fmt.Fprintf(&b, "    tab name=%s cwd=%s {\n", kdlString(w.Name), kdlString(w.Dir))
+		b.WriteString("        pane command=\"sh\" {\n")
+		fmt.Fprintf(&b, "            args \"-c\" %s\n", kdlString(w.Command))
+		b.WriteString("        }\n    }\n")
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// kdlString quotes a KDL string
+func kdlString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
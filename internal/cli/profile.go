@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pathsHome    string
+	pathsProfile string
+	pathsConfig  string
+)
+
+// AddPathFlags adds the flags that choose where grappler keeps its config,
+// state, logs and runtime files
+func AddPathFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&pathsHome, "home", "", "Grappler home directory (default $GRAPPLER_HOME or ~/.grappler)")
+	cmd.PersistentFlags().StringVar(&pathsProfile, "profile", "", "Profile to use, kept in <home>/profiles/<name> (default $GRAPPLER_PROFILE)")
+	cmd.PersistentFlags().StringVar(&pathsConfig, "config", "", "Config file (default $GRAPPLER_CONFIG or config.yaml in the profile)")
+}
+
+// ApplyPaths points grappler at the files chosen with the path flags
+func ApplyPaths(cmd *cobra.Command, args []string) error {
+	if err := config.SetPaths(pathsHome, pathsProfile, pathsConfig); err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+	return nil
+}
+
+// profileQualified prefixes a group name with the active profile, for names
+// all profiles share, such as systemd units and multiplexer sessions
+func profileQualified(groupName string) string {
+	if profile := config.Profile(); profile != "" {
+		return profile + "-" + groupName
+	}
+	return groupName
+}
@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/ports"
+	"github.com/kris-hansen/grappler/internal/process"
+)
+
+// resolveGroup interpolates ${...} references in a group's definitions using
+// the ports allocated in groupState, returning the resolved group and the
+// variables used
+func resolveGroup(groupName string, group *config.Group, groupState *config.GroupState) (*config.Group, *config.Vars, error) {
+	// Other groups work with a broken repo config; this one can't
+	if err := group.RepoConfigErr(); err != nil {
+		return nil, nil, fmt.Errorf("group %q: %w", groupName, err)
+	}
+
+	vars, err := config.NewVars(groupName, group, groupState.Ports())
+	if err != nil {
+		return nil, nil, fmt.Errorf("group %q: %w", groupName, err)
+	}
+
+	resolved, err := vars.ResolveGroup(group)
+	if err != nil {
+		return nil, nil, fmt.Errorf("group %q: %w", groupName, err)
+	}
+	if groupState != nil {
+		vars.Env = groupState.Env
+	}
+
+	return resolved, vars, nil
+}
+
+// portEnvVar returns the env var a service's allocated port is injected as
+func portEnvVar(serviceName string) string {
+	switch serviceName {
+	case "backend":
+		return "SERVER_PORT"
+	case "frontend":
+		return "CONDUCTOR_PORT"
+	}
+	return ""
+}
+
+// runtimeEnv returns the env vars grappler injects into a service: its port
+// variable and data directory, the group's discovery variables, database URL
+// and sidecar exports, and its env_from_services mappings
+func runtimeEnv(vars *config.Vars, group *config.Group, serviceName string) (map[string]string, error) {
+	envVars := vars.DiscoveryEnv()
+	if vars.Database != nil {
+		envVars[vars.Database.Env] = vars.Database.URL
+	}
+	addSidecarExports(envVars, group)
+
+	if svc := vars.Services[serviceName]; svc != nil {
+		if svc.Port > 0 {
+			envVars[portEnvVar(serviceName)] = strconv.Itoa(svc.Port)
+		}
+		envVars["GRAPPLER_DATA_DIR"] = svc.DataDir
+	}
+
+	service := group.Service(serviceName)
+	if service == nil {
+		return envVars, nil
+	}
+
+	for key, ref := range service.EnvFromServices {
+		name, field, ok := strings.Cut(ref, ".")
+		if !ok {
+			return nil, fmt.Errorf("group %q: %s.env_from_services.%s: expected <service>.<field>, got %q", vars.Group, serviceName, key, ref)
+		}
+		value, err := vars.ServiceValue(name, field)
+		if err != nil {
+			return nil, fmt.Errorf("group %q: %s.env_from_services.%s: %w", vars.Group, serviceName, key, err)
+		}
+		envVars[key] = value
+	}
+
+	return envVars, nil
+}
+
+// groupRuntimeEnv returns the env vars for group-level hooks: the discovery
+// variables, the port variable of every service, the group's database URL
+// and sidecar exports, and its data directory, which holds those of its
+// services
+func groupRuntimeEnv(vars *config.Vars, group *config.Group) map[string]string {
+	envVars := vars.DiscoveryEnv()
+	if vars.Database != nil {
+		envVars[vars.Database.Env] = vars.Database.URL
+	}
+	addSidecarExports(envVars, group)
+	envVars["GRAPPLER_DATA_DIR"] = filepath.Join(config.GetDataDir(), vars.Group)
+	for name, svc := range vars.Services {
+		if svc.Port > 0 {
+			envVars[portEnvVar(name)] = strconv.Itoa(svc.Port)
+		}
+	}
+	return envVars
+}
+
+// addSidecarExports adds the env vars the sidecars of a resolved group
+// export to envVars
+func addSidecarExports(envVars map[string]string, group *config.Group) {
+	for _, name := range group.SidecarNames() {
+		for key, value := range group.Sidecars[name].Export {
+			envVars[key] = value
+		}
+	}
+}
+
+// resolveDirectories returns a copy of a group with its service directories
+// interpolated, falling back to the group as written if they can't be
+// (pruneMissingDirectories has already warned about those)
+func resolveDirectories(groupName string, group *config.Group) *config.Group {
+	vars, err := config.NewVars(groupName, group, nil)
+	if err != nil {
+		return group
+	}
+	return vars.WithDirectories(group)
+}
+
+// serviceEnv builds the complete environment a service of a resolved group
+// runs with, exactly as start does
+func serviceEnv(vars *config.Vars, group *config.Group, serviceName string) (*process.Env, error) {
+	envVars, err := runtimeEnv(vars, group, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := process.BuildEnv(group, group.Service(serviceName), vars.Env, envVars)
+	if err != nil {
+		return nil, fmt.Errorf("group %q: %s: %w", vars.Group, serviceName, err)
+	}
+	return env, nil
+}
+
+// groupEnv builds the environment group-level hooks run with
+func groupEnv(vars *config.Vars, group *config.Group) (*process.Env, error) {
+	env, err := process.BuildEnv(group, nil, vars.Env, groupRuntimeEnv(vars, group))
+	if err != nil {
+		return nil, fmt.Errorf("group %q: %w", vars.Group, err)
+	}
+	return env, nil
+}
+
+// loadGroupRuntime loads a group from config and resolves it against the
+// ports it is running on, reporting whether it runs (under grappler or its
+// systemd units). Groups that don't are resolved against provisional ports,
+// which are not recorded in state.
+func loadGroupRuntime(groupName string) (*config.Group, *config.Vars, bool, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	group, exists := cfg.Groups[groupName]
+	if !exists {
+		return nil, nil, false, fmt.Errorf("group %q not found in config", groupName)
+	}
+
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	groupState := state.GetGroup(groupName)
+	// Groups run by systemd units keep the ports reserved for them
+	running := groupState != nil && (groupState.Running || groupState.Systemd)
+	if !running {
+		groupState, err = provisionalPorts(cfg, state, group)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	resolved, vars, err := resolveGroup(groupName, group, groupState)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return resolved, vars, running, nil
+}
+
+// provisionalPorts allocates ports for a group without recording them
+func provisionalPorts(cfg *config.Config, state *config.State, group *config.Group) (*config.GroupState, error) {
+	allocator := ports.NewAllocator(state, cfg.Ports)
+	groupState := &config.GroupState{}
+
+	var err error
+	if group.Backend != nil {
+		if groupState.BackendPort, err = allocator.AllocateBackendPort(); err != nil {
+			return nil, fmt.Errorf("failed to allocate backend port: %w", err)
+		}
+	}
+	if group.Frontend != nil {
+		if groupState.FrontendPort, err = allocator.AllocateFrontendPort(); err != nil {
+			return nil, fmt.Errorf("failed to allocate frontend port: %w", err)
+		}
+	}
+	for _, name := range group.SidecarNames() {
+		port, err := sidecarPort(allocator, state, name, group.Sidecars[name], 0)
+		if err != nil {
+			return nil, err
+		}
+		groupState.SetSidecar(name, &config.SidecarState{Port: port, Shared: group.Sidecars[name].Shared()})
+	}
+
+	return groupState, nil
+}
+
+// selectService returns the service named in args after the group, or the
+// group's first service when none is given
+func selectService(group *config.Group, groupName string, args []string) (string, error) {
+	if len(args) > 1 {
+		if group.Service(args[1]) == nil {
+			return "", fmt.Errorf("group %q has no service %q", groupName, args[1])
+		}
+		return args[1], nil
+	}
+
+	services := group.ServiceList()
+	if len(services) == 0 {
+		return "", fmt.Errorf("group %q has no services", groupName)
+	}
+	return services[0].Name, nil
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/spf13/cobra"
+)
+
+var restartBulk bulkOptions
+
+// RestartCmd returns the restart command
+func RestartCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restart [group...]",
+		Short: "Restart worktree groups",
+		Long: `Stops and starts worktree groups, keeping their ports where they are still free. Groups that aren't running are started.
+
+With a group and one of its services ('grappler restart main backend'), restarts just that service on the same port.
+
+Groups are selected like for 'grappler start'.`,
+		Args:         cobra.ArbitraryArgs,
+		SilenceUsage: true,
+		RunE:         runRestart,
+	}
+
+	cmd.Flags().BoolVar(&startForceSetup, "setup", false, "Run setup hooks again even if they already completed")
+	addEnvFlag(cmd)
+	addBulkFlags(cmd, &restartBulk)
+
+	return cmd
+}
+
+func runRestart(cmd *cobra.Command, args []string) error {
+	cfg, state, err := loadConfigAndState()
+	if err != nil {
+		return err
+	}
+
+	env, err := parseEnvOverrides(startEnv)
+	if err != nil {
+		return err
+	}
+
+	if serviceTarget(cfg, &restartBulk, args) {
+		if env != nil {
+			return fmt.Errorf("--env applies to the whole group; restart the group to change its env")
+		}
+		return restartGroupService(cfg, state, args[0], args[1], os.Stdout)
+	}
+	if restartBulk.single(args) {
+		return restartGroup(cfg, state, args[0], env, os.Stdout)
+	}
+
+	names, err := restartBulk.selectGroups(cfg, args, configGroupNames(cfg), func(string) bool { return true })
+	if err != nil {
+		return err
+	}
+
+	return runBulk(names, restartBulk.parallel, func(groupName string, out io.Writer) (string, string, error) {
+		result := "started"
+		if isGroupRunning(state, groupName) {
+			result = "restarted"
+		}
+		if err := restartGroup(cfg, state, groupName, env, out); err != nil {
+			return "", "", err
+		}
+		return result, describePorts(state.GetGroup(groupName)), nil
+	})
+}
+
+// restartGroup stops a group if it is running and starts it again on the
+// same ports where possible, keeping its env overrides with env on top
+func restartGroup(cfg *config.Config, state *config.State, groupName string, env map[string]string, out io.Writer) error {
+	if cfg.Groups[groupName] == nil {
+		return fmt.Errorf("group %q not found in config", groupName)
+	}
+
+	var preferred map[string]int
+	if groupState := state.GetGroup(groupName); groupState != nil && groupState.Running {
+		preferred = groupState.Ports()
+		env = mergeEnv(groupState.Env, env)
+		pids := []int{groupState.BackendPID, groupState.FrontendPID}
+		pids = append(pids, sidecarPIDs(state, groupName, groupState)...)
+
+		if err := stopGroup(state, groupName, out); err != nil {
+			return err
+		}
+
+		// Give the old processes a chance to release their ports
+		waitForExit(out, process.NewManager(config.GetLogsDir()), pids, 10*time.Second)
+		fmt.Fprintln(out)
+	}
+
+	return startGroupIn(cfg, state, groupName, startOptions{out: out, ports: preferred, env: env})
+}
+
+// mergeEnv returns the variables of base with those of overrides on top
+func mergeEnv(base, overrides map[string]string) map[string]string {
+	if len(base) == 0 {
+		return overrides
+	}
+
+	merged := make(map[string]string, len(base)+len(overrides))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+// waitForExit waits up to timeout for stopped processes to exit
+func waitForExit(out io.Writer, procMgr *process.Manager, pids []int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for _, pid := range pids {
+		for procMgr.IsProcessRunning(pid) {
+			if time.Now().After(deadline) {
+				fmt.Fprintf(out, "⚠ Process %d is still running\n", pid)
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/kris-hansen/grappler/internal/systemd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resumeParallel      int
+	resumeForget        bool
+	resumeInstallUnit   bool
+	resumeUninstallUnit bool
+)
+
+// ResumeCmd returns the resume command
+func ResumeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resume [group...]",
+		Short: "Start the groups that were running before a reboot",
+		Long: `When the machine restarts, grappler notices on its next run and keeps a list of the groups that were running. 'grappler resume' starts them again with the same services and env overrides, on the same ports where they are still free.
+
+With --install-unit, grappler installs and enables a systemd user unit that runs 'grappler resume' when the user's service manager starts, so groups come back automatically after a reboot.`,
+		Args:         cobra.ArbitraryArgs,
+		SilenceUsage: true,
+		RunE:         runResume,
+	}
+
+	cmd.Flags().IntVar(&resumeParallel, "parallel", 1, "Number of groups to start at once")
+	cmd.Flags().BoolVar(&resumeForget, "forget", false, "Discard the groups waiting to be resumed instead of starting them")
+	cmd.Flags().BoolVar(&resumeInstallUnit, "install-unit", false, "Install a systemd user unit that resumes groups automatically")
+	cmd.Flags().BoolVar(&resumeUninstallUnit, "uninstall-unit", false, "Remove the systemd user unit installed with --install-unit")
+
+	return cmd
+}
+
+// CheckReboot detects that the machine restarted since groups were started.
+// Their PIDs are stale then, so they are moved to the groups waiting for
+// 'grappler resume'. It runs before every command and only reports problems
+// on stderr, keeping stdout clean for commands like 'env --export'. migrate
+// is skipped, as saving would upgrade the state without a backup. Without a
+// state file there is nothing to check, and none is created.
+func CheckReboot(cmd *cobra.Command, args []string) {
+	if cmd.Name() == "migrate" {
+		return
+	}
+	if _, err := os.Stat(config.GetStatePath()); err != nil {
+		return
+	}
+
+	bootID, err := process.BootID()
+	if err != nil {
+		return
+	}
+
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		return
+	}
+
+	changed, stale := state.RecordBoot(bootID)
+	if !changed {
+		return
+	}
+	if err := state.Save(config.GetStatePath()); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ Failed to save state: %v\n", err)
+		return
+	}
+
+	if len(stale) > 0 && cmd.Name() != "resume" {
+		fmt.Fprintf(os.Stderr, "⚠ The system restarted while %s were running; run 'grappler resume' to start them again\n", strings.Join(stale, ", "))
+	}
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	if resumeInstallUnit {
+		return installResumeUnit()
+	}
+	if resumeUninstallUnit {
+		return uninstallResumeUnit()
+	}
+
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	pending := state.ResumeGroups()
+	names := args
+	if len(names) == 0 {
+		for name := range pending {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if pending[name] == nil {
+			return fmt.Errorf("group %q is not waiting to be resumed", name)
+		}
+	}
+
+	if len(names) == 0 {
+		fmt.Println("Nothing to resume")
+		return nil
+	}
+
+	if resumeForget {
+		for _, name := range names {
+			state.ClearResume(name)
+		}
+		if err := state.Save(config.GetStatePath()); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+		fmt.Printf("✓ Forgot %s\n", strings.Join(names, ", "))
+		return nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	return runBulk(names, resumeParallel, func(groupName string, out io.Writer) (string, string, error) {
+		saved := pending[groupName]
+
+		if !isGroupRunning(state, groupName) {
+			opts := startOptions{
+				out:      out,
+				ports:    saved.Ports,
+				env:      saved.Env,
+				services: saved.Services,
+			}
+			if err := startGroupIn(cfg, state, groupName, opts); err != nil {
+				return "", "", err
+			}
+		}
+
+		state.ClearResume(groupName)
+		if err := state.Save(config.GetStatePath()); err != nil {
+			return "", "", fmt.Errorf("failed to save state: %w", err)
+		}
+		return "resumed", describePorts(state.GetGroup(groupName)), nil
+	})
+}
+
+// installResumeUnit installs and enables the systemd user unit that runs
+// 'grappler resume' when the user's service manager starts
+func installResumeUnit() error {
+	resumeUnit := resumeUnitName()
+	path, err := systemd.InstallUnit(resumeUnit, resumeUnitContent())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Wrote %s\n", path)
+
+	if err := systemd.Systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := systemd.Systemctl("enable", resumeUnit); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Enabled %s\n", resumeUnit)
+	fmt.Println("\nGroups will resume when you log in. To resume them at boot without logging in, run:")
+	fmt.Println("  loginctl enable-linger $USER")
+	return nil
+}
+
+// uninstallResumeUnit disables and removes the resume unit
+func uninstallResumeUnit() error {
+	resumeUnit := resumeUnitName()
+	if err := systemd.Systemctl("disable", resumeUnit); err != nil {
+		fmt.Printf("⚠ %v\n", err)
+	}
+	if err := systemd.RemoveUnit(resumeUnit); err != nil {
+		return err
+	}
+	if err := systemd.Systemctl("daemon-reload"); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Removed %s\n", resumeUnit)
+	return nil
+}
+
+// resumeUnitName returns the systemd user unit that resumes the groups of
+// the active profile at login
+func resumeUnitName() string {
+	if profile := config.Profile(); profile != "" {
+		return fmt.Sprintf("grappler-%s-resume.service", profile)
+	}
+	return "grappler-resume.service"
+}
+
+// resumeUnitContent returns the resume unit. The services it starts must
+// outlive the oneshot 'grappler resume', so only the main process is
+// killed when the unit stops. PATH is captured from the installing shell
+// because the user manager's PATH rarely has pnpm, go and friends, and the
+// home, profile and config along with it.
+func resumeUnitContent() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, "[Unit]")
+	fmt.Fprintln(&b, "Description=Resume grappler groups after a reboot")
+	fmt.Fprintln(&b, "After=network-online.target")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "[Service]")
+	fmt.Fprintln(&b, "Type=oneshot")
+	fmt.Fprintln(&b, "RemainAfterExit=yes")
+	fmt.Fprintln(&b, "KillMode=process")
+	fmt.Fprintf(&b, "Environment=%s\n", systemd.QuoteEnv("PATH", os.Getenv("PATH")))
+	for _, key := range []string{config.HomeEnv, config.ProfileEnv, config.ConfigEnv} {
+		if value := os.Getenv(key); value != "" {
+			fmt.Fprintf(&b, "Environment=%s\n", systemd.QuoteEnv(key, value))
+		}
+	}
+	fmt.Fprintf(&b, "ExecStart=%s resume\n", systemd.QuoteArg(grapplerExecutable()))
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "[Install]")
+	fmt.Fprintln(&b, "WantedBy=default.target")
+	return b.String()
+}
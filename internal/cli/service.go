@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+)
+
+// serviceTarget reports whether args name one service of a group, as in
+// 'grappler start <group> <service>', rather than two groups
+func serviceTarget(cfg *config.Config, opts *bulkOptions, args []string) bool {
+	if len(args) != 2 || opts.all || len(opts.selectors) > 0 {
+		return false
+	}
+	group := cfg.Groups[args[0]]
+	return group != nil && group.Service(args[1]) != nil && cfg.Groups[args[1]] == nil
+}
+
+// runningGroupState returns the state of a running group that has a service
+func runningGroupState(cfg *config.Config, state *config.State, groupName, serviceName string) (*config.Group, *config.GroupState, error) {
+	group := cfg.Groups[groupName]
+	if group == nil {
+		return nil, nil, fmt.Errorf("group %q not found in config", groupName)
+	}
+	if group.Service(serviceName) == nil {
+		return nil, nil, fmt.Errorf("group %q has no service %q", groupName, serviceName)
+	}
+
+	groupState := state.GetGroup(groupName)
+	if groupState == nil || !groupState.Running {
+		return nil, nil, fmt.Errorf("group %q is not running (start it with 'grappler start %s')", groupName, groupName)
+	}
+
+	return group, groupState, nil
+}
+
+// startGroupService starts one service of a running group on the port it
+// already has, leaving the other services alone. Only the service's hooks
+// run, not the group's.
+func startGroupService(cfg *config.Config, state *config.State, groupName, serviceName string, out io.Writer) error {
+	group, groupState, err := runningGroupState(cfg, state, groupName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	procMgr := process.NewManager(config.GetLogsDir())
+	if procMgr.IsProcessRunning(groupState.PID(serviceName)) {
+		return fmt.Errorf("service %q of group %q is already running", serviceName, groupName)
+	}
+
+	fmt.Fprintf(out, "Starting %s of group %q...\n", serviceName, groupName)
+
+	group, vars, err := resolveGroup(groupName, group, groupState)
+	if err != nil {
+		return err
+	}
+
+	env, err := serviceEnv(vars, group, serviceName)
+	if err != nil {
+		return err
+	}
+	if err := prepareDataDir(out, vars, group, serviceName); err != nil {
+		return err
+	}
+
+	if err := procMgr.ResetLog(groupName, serviceName); err != nil {
+		return err
+	}
+
+	hooks := &hookRunner{
+		procMgr:    procMgr,
+		state:      state,
+		group:      group,
+		groupName:  groupName,
+		forceSetup: startForceSetup,
+		out:        out,
+	}
+
+	launch := func(service *config.Service, serviceName string, env []string) (int, []string, error) {
+		return procMgr.StartService(service, serviceName, groupName, env)
+	}
+	if err := startService(hooks, launch, groupState, serviceName, env.List()); err != nil {
+		return err
+	}
+
+	groupState.SetStopped(serviceName, false)
+	if err := state.Save(config.GetStatePath()); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	if port := groupState.Port(serviceName); port > 0 {
+		fmt.Fprintln(out)
+		waitHealthy(out, groupName, serviceName, group.Service(serviceName).Health, port)
+	}
+
+	// A failing post-start hook stops the service again
+	if err := hooks.runService(config.HookPostStart, group.Service(serviceName), serviceName, env.List()); err != nil {
+		procMgr.StopProcess(groupState.PID(serviceName))
+		groupState.SetPID(serviceName, 0)
+		groupState.SetStopped(serviceName, true)
+		if err := state.Save(config.GetStatePath()); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+		return hookFailed(out, err, procMgr.LogPath(groupName, serviceName))
+	}
+
+	fmt.Fprintf(out, "\n✓ %s of group %q is running\n", serviceTitle(serviceName), groupName)
+	return nil
+}
+
+// stopGroupService stops one service of a running group, keeping its port
+// reserved so it can be started again on the same port
+func stopGroupService(cfg *config.Config, state *config.State, groupName, serviceName string, out io.Writer) error {
+	_, groupState, err := runningGroupState(cfg, state, groupName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	pid := groupState.PID(serviceName)
+	if pid == 0 {
+		return fmt.Errorf("service %q of group %q is not running", serviceName, groupName)
+	}
+
+	fmt.Fprintf(out, "Stopping %s of group %q...\n", serviceName, groupName)
+
+	procMgr := process.NewManager(config.GetLogsDir())
+	hooks, vars := stopHooks(procMgr, state, groupName, groupState, out)
+	stopService(hooks, vars, serviceName, pid, procMgr.StopProcess)
+
+	groupState.SetPID(serviceName, 0)
+	groupState.SetStopped(serviceName, true)
+	if err := state.Save(config.GetStatePath()); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	if port := groupState.Port(serviceName); port > 0 {
+		fmt.Fprintf(out, "\n✓ %s of group %q stopped (port %d stays reserved)\n", serviceTitle(serviceName), groupName, port)
+	} else {
+		fmt.Fprintf(out, "\n✓ %s of group %q stopped\n", serviceTitle(serviceName), groupName)
+	}
+	return nil
+}
+
+// restartGroupService stops one service of a running group if it is running
+// and starts it again on the same port
+func restartGroupService(cfg *config.Config, state *config.State, groupName, serviceName string, out io.Writer) error {
+	_, groupState, err := runningGroupState(cfg, state, groupName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	procMgr := process.NewManager(config.GetLogsDir())
+	if pid := groupState.PID(serviceName); procMgr.IsProcessRunning(pid) {
+		if err := stopGroupService(cfg, state, groupName, serviceName, out); err != nil {
+			return err
+		}
+
+		// Give the old process a chance to release its port
+		waitForExit(out, procMgr, []int{pid}, 10*time.Second)
+		fmt.Fprintln(out)
+	}
+
+	return startGroupService(cfg, state, groupName, serviceName, out)
+}
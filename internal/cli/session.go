@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/spf13/cobra"
+)
+
+var sessionParallel int
+
+// SessionCmd returns the session command
+func SessionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "session",
+		Short: "Save and restore sets of running groups",
+		Long: `Sessions capture which groups and services are running, with their ports and env overrides, so a set of groups can be brought back with one command.
+
+Sessions are stored in ~/.grappler/sessions.`,
+	}
+
+	saveCmd := &cobra.Command{
+		Use:   "save <name>",
+		Short: "Save the running groups as a session",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runSessionSave,
+	}
+
+	restoreCmd := &cobra.Command{
+		Use:          "restore <name>",
+		Short:        "Stop every other group and bring a session's groups back up",
+		Long:         `Stops every running group that isn't part of the session, then starts the session's groups with the ports, env overrides and services they had when it was saved. Groups already running exactly as saved are left alone.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runSessionRestore,
+	}
+	restoreCmd.Flags().IntVar(&sessionParallel, "parallel", 1, "Number of groups to act on at once")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved sessions",
+		Args:  cobra.NoArgs,
+		RunE:  runSessionList,
+	}
+
+	rmCmd := &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Delete a saved session",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runSessionRm,
+	}
+
+	cmd.AddCommand(saveCmd, restoreCmd, listCmd, rmCmd)
+	return cmd
+}
+
+func runSessionSave(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := validateSessionName(name); err != nil {
+		return err
+	}
+
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	procMgr := process.NewManager(config.GetLogsDir())
+	session := &config.Session{
+		Name:    name,
+		SavedAt: time.Now(),
+		Groups:  make(map[string]*config.SessionGroup),
+	}
+
+	for groupName, groupState := range state.GroupStates() {
+		if !groupState.Running {
+			continue
+		}
+
+		var services []string
+		for _, svc := range groupState.ServiceNames() {
+			if procMgr.IsProcessRunning(groupState.PID(svc)) {
+				services = append(services, svc)
+			}
+		}
+		if len(services) == 0 {
+			continue
+		}
+
+		session.Groups[groupName] = &config.SessionGroup{
+			Services: services,
+			Ports:    groupState.Ports(),
+			Env:      groupState.Env,
+		}
+	}
+
+	if len(session.Groups) == 0 {
+		return fmt.Errorf("no groups are running")
+	}
+
+	if err := session.Save(config.GetSessionPath(name)); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Saved session %q: %s\n", name, strings.Join(sessionGroupNames(session), ", "))
+	return nil
+}
+
+func runSessionRestore(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := validateSessionName(name); err != nil {
+		return err
+	}
+
+	session, err := loadSession(name)
+	if err != nil {
+		return err
+	}
+
+	cfg, state, err := loadConfigAndState()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restoring session %q...\n\n", name)
+	procMgr := process.NewManager(config.GetLogsDir())
+
+	// Stop the groups outside the session, and session groups running
+	// differently from how they were saved
+	var others, changed, unchanged []string
+	var pids []int
+	for groupName, groupState := range state.GroupStates() {
+		if !groupState.Running {
+			continue
+		}
+
+		saved := session.Groups[groupName]
+		switch {
+		case saved == nil:
+			others = append(others, groupName)
+		case matchesSession(procMgr, groupState, saved):
+			unchanged = append(unchanged, groupName)
+			continue
+		default:
+			changed = append(changed, groupName)
+		}
+
+		for _, svc := range groupState.ServiceNames() {
+			pids = append(pids, groupState.PID(svc))
+		}
+	}
+	sort.Strings(others)
+	sort.Strings(unchanged)
+
+	stop := func(groupName string, out io.Writer) (string, string, error) {
+		if err := stopGroup(state, groupName, out); err != nil {
+			return "", "", err
+		}
+		return "stopped", "", nil
+	}
+
+	results := runBulkActions(others, sessionParallel, stop)
+	for _, r := range runBulkActions(changed, sessionParallel, stop) {
+		if r.result == "failed" {
+			results = append(results, r)
+		}
+	}
+
+	// Give stopped processes a chance to release their ports
+	waitForExit(os.Stdout, procMgr, pids, 10*time.Second)
+
+	for _, groupName := range unchanged {
+		results = append(results, bulkResult{
+			group:  groupName,
+			result: "unchanged",
+			detail: describePorts(state.GetGroup(groupName)),
+		})
+	}
+
+	var toStart []string
+	for _, groupName := range sessionGroupNames(session) {
+		if !isGroupRunning(state, groupName) {
+			toStart = append(toStart, groupName)
+		}
+	}
+
+	results = append(results, runBulkActions(toStart, sessionParallel, func(groupName string, out io.Writer) (string, string, error) {
+		saved := session.Groups[groupName]
+		opts := startOptions{
+			out:      out,
+			ports:    saved.Ports,
+			env:      saved.Env,
+			services: saved.Services,
+		}
+		if err := startGroupIn(cfg, state, groupName, opts); err != nil {
+			return "", "", err
+		}
+
+		groupState := state.GetGroup(groupName)
+		detail := describePorts(groupState)
+		for svc, port := range saved.Ports {
+			if groupState.Port(svc) != port {
+				detail += " (ports changed)"
+				break
+			}
+		}
+		return "started", detail, nil
+	})...)
+
+	return printBulkSummary(results)
+}
+
+func runSessionList(cmd *cobra.Command, args []string) error {
+	names, err := config.ListSessions()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		fmt.Println("No saved sessions")
+		return nil
+	}
+
+	fmt.Printf("%-20s %-20s %s\n", "SESSION", "SAVED", "GROUPS")
+	fmt.Println(repeatString("-", 80))
+	for _, name := range names {
+		session, err := loadSession(name)
+		if err != nil {
+			fmt.Printf("%-20s ⚠ %v\n", name, err)
+			continue
+		}
+		fmt.Printf("%-20s %-20s %s\n", name, session.SavedAt.Format("2006-01-02 15:04"), strings.Join(sessionGroupNames(session), ", "))
+	}
+
+	return nil
+}
+
+func runSessionRm(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := validateSessionName(name); err != nil {
+		return err
+	}
+
+	if err := os.Remove(config.GetSessionPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("session %q not found", name)
+		}
+		return fmt.Errorf("failed to remove session: %w", err)
+	}
+
+	fmt.Printf("✓ Removed session %q\n", name)
+	return nil
+}
+
+// loadSession loads a saved session by name
+func loadSession(name string) (*config.Session, error) {
+	path := config.GetSessionPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("session %q not found", name)
+	}
+	return config.LoadSession(path)
+}
+
+// validateSessionName rejects names that can't be used as a file name
+func validateSessionName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid session name %q", name)
+	}
+	return nil
+}
+
+// sessionGroupNames returns the names of a session's groups, sorted
+func sessionGroupNames(session *config.Session) []string {
+	var names []string
+	for name := range session.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchesSession reports whether a running group has the ports, env
+// overrides and running services it was saved with
+func matchesSession(procMgr *process.Manager, groupState *config.GroupState, saved *config.SessionGroup) bool {
+	if !equalMaps(groupState.Ports(), saved.Ports) || !equalMaps(groupState.Env, saved.Env) {
+		return false
+	}
+
+	var running []string
+	for _, svc := range groupState.ServiceNames() {
+		if procMgr.IsProcessRunning(groupState.PID(svc)) {
+			running = append(running, svc)
+		}
+	}
+	return strings.Join(running, ",") == strings.Join(saved.Services, ",")
+}
+
+// equalMaps reports whether two maps hold the same entries, treating nil
+// and empty maps alike
+func equalMaps[V comparable](a, b map[string]V) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/ports"
+	"github.com/kris-hansen/grappler/internal/process"
+)
+
+// sharedSidecarLog is the group name the logs of shared sidecars are kept
+// under, as they belong to no one group
+const sharedSidecarLog = "_shared"
+
+// sidecarsMu serializes starting shared sidecars, so groups started in
+// parallel start each only once
+var sidecarsMu sync.Mutex
+
+// sidecarPort returns the port a sidecar of a group runs on: that of the
+// running instance for a shared sidecar, or a newly allocated one, keeping
+// preferred if it is still free
+func sidecarPort(allocator *ports.Allocator, state *config.State, name string, sidecar *config.Sidecar, preferred int) (int, error) {
+	if sidecar.Shared() {
+		if shared := state.SharedSidecar(name); shared != nil {
+			if shared.PID == 0 || process.NewManager(config.GetLogsDir()).IsProcessRunning(shared.PID) {
+				return shared.Port, nil
+			}
+			// It died; start it again where its users expect it if possible
+			if preferred == 0 {
+				preferred = shared.Port
+			}
+		}
+	}
+
+	port, err := allocator.AllocateSidecarPortPreferring(preferred)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate a port for sidecar %s: %w", name, err)
+	}
+	allocator.Reserve(port)
+	return port, nil
+}
+
+// reserveSidecars allocates the ports of a group's sidecars into
+// groupState. Shared sidecars not running yet are recorded in state, so
+// groups started alongside use the same instance.
+func reserveSidecars(allocator *ports.Allocator, state *config.State, groupName string, group *config.Group, groupState *config.GroupState, preferred map[string]int) error {
+	for _, name := range group.SidecarNames() {
+		sidecar := group.Sidecars[name]
+		port, err := sidecarPort(allocator, state, name, sidecar, preferred[name])
+		if err != nil {
+			return err
+		}
+
+		if sidecar.Shared() {
+			if shared := state.SharedSidecar(name); shared == nil {
+				state.SetSharedSidecar(name, &config.SidecarState{Port: port, Shared: true})
+			} else if shared.Port != port {
+				// Restarting a dead one on a new port; its users stay attached
+				state.SetSharedSidecar(name, &config.SidecarState{Port: port, Shared: true, PID: shared.PID, Groups: shared.Groups})
+			}
+			state.AttachSidecar(name, groupName)
+		}
+		groupState.SetSidecar(name, &config.SidecarState{Port: port, Shared: sidecar.Shared()})
+	}
+	return nil
+}
+
+// startSidecars starts the sidecars of a resolved group that aren't running
+// yet, waiting for each to accept connections, and records their processes
+func startSidecars(out io.Writer, procMgr *process.Manager, state *config.State, vars *config.Vars, group *config.Group, groupState *config.GroupState) error {
+	for _, name := range group.SidecarNames() {
+		sidecar := group.Sidecars[name]
+		sidecarState := groupState.Sidecars[name]
+
+		if !sidecar.Shared() {
+			pid, argv, err := startSidecar(out, procMgr, vars, name, sidecar, sidecarState.Port)
+			if err != nil {
+				return err
+			}
+			sidecarState.PID, sidecarState.Argv = pid, argv
+			continue
+		}
+
+		if err := startSharedSidecar(out, procMgr, state, vars, name, sidecar, sidecarState.Port); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSharedSidecar starts a shared sidecar unless another group already
+// has, recording its process in state
+func startSharedSidecar(out io.Writer, procMgr *process.Manager, state *config.State, vars *config.Vars, name string, sidecar *config.Sidecar, port int) error {
+	sidecarsMu.Lock()
+	defer sidecarsMu.Unlock()
+
+	if shared := state.SharedSidecar(name); shared != nil && shared.PID > 0 && procMgr.IsProcessRunning(shared.PID) {
+		fmt.Fprintf(out, "  %-15s%d (shared, PID: %d)\n", "Sidecar "+name+":", shared.Port, shared.PID)
+		return nil
+	}
+
+	pid, argv, err := startSidecar(out, procMgr, vars, name, sidecar, port)
+	if err != nil {
+		return err
+	}
+
+	shared := state.SharedSidecar(name)
+	if shared == nil {
+		shared = &config.SidecarState{Port: port, Shared: true}
+		state.SetSharedSidecar(name, shared)
+		state.AttachSidecar(name, vars.Group)
+	}
+	shared.PID, shared.Argv = pid, argv
+	return state.Save(config.GetStatePath())
+}
+
+// startSidecar launches a sidecar on its port and waits for it to accept
+// connections, stopping it if it doesn't
+func startSidecar(out io.Writer, procMgr *process.Manager, vars *config.Vars, name string, sidecar *config.Sidecar, port int) (int, []string, error) {
+	sv := vars.Sidecars[name]
+	logGroup := vars.Group
+	envVars := vars.DiscoveryEnv()
+	if sv.Shared {
+		// A shared sidecar outlives the group starting it
+		logGroup = sharedSidecarLog
+		envVars = make(map[string]string)
+	}
+	envVars["PORT"] = strconv.Itoa(port)
+	envVars["GRAPPLER_DATA_DIR"] = sv.DataDir
+
+	if err := os.MkdirAll(sv.DataDir, 0755); err != nil {
+		return 0, nil, fmt.Errorf("failed to create data directory of sidecar %s: %w", name, err)
+	}
+	if err := procMgr.ResetLog(logGroup, name); err != nil {
+		return 0, nil, err
+	}
+
+	service := &config.Service{
+		Directory: sidecar.Directory,
+		Command:   sidecar.Command,
+		Env:       sidecar.Env,
+	}
+	env, err := process.BuildEnv(nil, service, nil, envVars)
+	if err != nil {
+		return 0, nil, fmt.Errorf("sidecar %s: %w", name, err)
+	}
+
+	fmt.Fprintf(out, "\nStarting sidecar %s...\n", name)
+	pid, argv, err := procMgr.StartDetached(service, name, logGroup, env.List())
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to start sidecar %s: %w", name, err)
+	}
+
+	timeout := 30 * time.Second
+	if sidecar.Timeout != "" {
+		if d, err := time.ParseDuration(sidecar.Timeout); err != nil {
+			fmt.Fprintf(out, "⚠ Sidecar %s timeout: %v; waiting %s\n", name, err, timeout)
+		} else {
+			timeout = d
+		}
+	}
+
+	exited := func() bool { return !procMgr.IsProcessRunning(pid) }
+	if err := process.NewHealthChecker().WaitForPort(port, timeout, exited); err != nil {
+		procMgr.SignalGroup(pid, syscall.SIGTERM)
+		fmt.Fprintf(out, "✗ Sidecar %s: %v\n", name, err)
+		fmt.Fprintf(out, "  Check logs: %s\n", procMgr.LogPath(logGroup, name))
+		return 0, nil, fmt.Errorf("sidecar %s did not start", name)
+	}
+
+	fmt.Fprintf(out, "✓ Sidecar %s listening on :%d (PID: %d)\n", name, port, pid)
+	return pid, argv, nil
+}
+
+// stopSidecars stops the sidecars of a group, leaving shared ones running
+// while other groups use them. It only updates state; callers save it.
+// Sidecars already gone are skipped, so it is safe to call more than once.
+func stopSidecars(out io.Writer, state *config.State, groupName string, groupState *config.GroupState) {
+	if groupState == nil {
+		return
+	}
+	procMgr := process.NewManager(config.GetLogsDir())
+
+	for _, name := range sortedSidecarNames(groupState) {
+		pid := groupState.Sidecars[name].PID
+		if groupState.Sidecars[name].Shared {
+			last := state.DetachSidecar(name, groupName)
+			if last == nil {
+				if shared := state.SharedSidecar(name); shared != nil {
+					fmt.Fprintf(out, "  Sidecar %s left running for %s\n", name, strings.Join(shared.Groups, ", "))
+				}
+				continue
+			}
+			pid = last.PID
+		}
+
+		if !procMgr.IsProcessRunning(pid) {
+			continue
+		}
+		fmt.Fprintf(out, "Stopping sidecar %s (PID: %d)...\n", name, pid)
+		// Sidecars run in a process group of their own; stop all of it
+		if err := procMgr.SignalGroup(pid, syscall.SIGTERM); err != nil {
+			fmt.Fprintf(out, "⚠ Failed to stop sidecar %s: %v\n", name, err)
+		} else {
+			fmt.Fprintf(out, "✓ Sidecar %s stopped\n", name)
+		}
+	}
+}
+
+// sidecarPIDs returns the processes of the sidecars that stopping a group
+// stops: its own, and shared ones no other group uses
+func sidecarPIDs(state *config.State, groupName string, groupState *config.GroupState) []int {
+	var pids []int
+	for name, sidecar := range groupState.Sidecars {
+		if !sidecar.Shared {
+			pids = append(pids, sidecar.PID)
+			continue
+		}
+		shared := state.SharedSidecar(name)
+		if shared != nil && len(shared.Groups) == 1 && shared.Groups[0] == groupName {
+			pids = append(pids, shared.PID)
+		}
+	}
+	return pids
+}
+
+// describeSidecars summarizes the sidecars a group uses for status
+func describeSidecars(groupState *config.GroupState) string {
+	var parts []string
+	for _, name := range sortedSidecarNames(groupState) {
+		part := fmt.Sprintf("%s :%d", name, groupState.Sidecars[name].Port)
+		if groupState.Sidecars[name].Shared {
+			part += " (shared)"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedSidecarNames(groupState *config.GroupState) []string {
+	names := make([]string, 0, len(groupState.Sidecars))
+	for name := range groupState.Sidecars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,8 +1,13 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kris-hansen/grappler/internal/config"
@@ -11,24 +16,168 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	startForceSetup bool
+	startEnv        []string
+	startBulk       bulkOptions
+)
+
 // StartCmd returns the start command
 func StartCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "start <group>",
-		Short: "Start a worktree group",
-		Long:  `Starts the backend and frontend services for a worktree group with allocated ports.`,
-		Args:  cobra.ExactArgs(1),
-		RunE:  runStart,
+	cmd := &cobra.Command{
+		Use:   "start [group...]",
+		Short: "Start worktree groups",
+		Long: `Starts the backend and frontend services for worktree groups with allocated ports.
+
+With a group and one of its services ('grappler start main backend'), starts just that service of a running group, on the port it already has.
+
+Groups can be named directly, matched with glob patterns (e.g. 'feature-*'), selected by label with -l, or all at once with --all. When more than one group is selected, a summary of each group's result is printed at the end.`,
+		Args:         cobra.ArbitraryArgs,
+		SilenceUsage: true,
+		RunE:         runStart,
 	}
+
+	cmd.Flags().BoolVar(&startForceSetup, "setup", false, "Run setup hooks again even if they already completed")
+	addEnvFlag(cmd)
+	addBulkFlags(cmd, &startBulk)
+
+	return cmd
+}
+
+// serviceLauncher starts a service process and returns its PID and argv
+type serviceLauncher func(service *config.Service, serviceName string, env []string) (int, []string, error)
+
+// startOptions controls how startGroup starts a group
+type startOptions struct {
+	// launch starts each service; nil starts them detached, logging to file
+	launch serviceLauncher
+	// out receives progress output; nil writes to stdout
+	out io.Writer
+	// ports holds preferred ports by service name, kept if still free
+	ports map[string]int
+	// env holds per-run env overrides for every service of the group
+	env map[string]string
+	// services limits which services start; the others are recorded as
+	// stopped, keeping their ports. nil starts every service.
+	services []string
+	// session names the multiplexer that runs the services in the
+	// foreground: the group is set up, start hooks included, but no service
+	// is launched
+	session string
+}
+
+// skips reports whether a service is left stopped
+func (o startOptions) skips(serviceName string) bool {
+	if o.services == nil {
+		return false
+	}
+	for _, name := range o.services {
+		if name == serviceName {
+			return false
+		}
+	}
+	return true
+}
+
+// addEnvFlag adds the --env flag for per-run env overrides to a command
+func addEnvFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&startEnv, "env", "e", nil, "Set an env var for every service of the group for this run (KEY=VALUE, repeatable)")
+}
+
+// parseEnvOverrides parses KEY=VALUE pairs given with --env
+func parseEnvOverrides(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	env := make(map[string]string)
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --env %q: expected KEY=VALUE", pair)
+		}
+		env[key] = value
+	}
+	return env, nil
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
-	groupName := args[0]
+	cfg, state, err := loadConfigAndState()
+	if err != nil {
+		return err
+	}
+
+	env, err := parseEnvOverrides(startEnv)
+	if err != nil {
+		return err
+	}
+
+	if serviceTarget(cfg, &startBulk, args) {
+		if env != nil {
+			return fmt.Errorf("--env applies to the whole group; restart the group to change its env")
+		}
+		return startGroupService(cfg, state, args[0], args[1], os.Stdout)
+	}
+	if startBulk.single(args) {
+		return startGroupIn(cfg, state, args[0], startOptions{env: env})
+	}
+
+	names, err := startBulk.selectGroups(cfg, args, configGroupNames(cfg), func(string) bool { return true })
+	if err != nil {
+		return err
+	}
 
-	// Load config
+	return runBulk(names, startBulk.parallel, func(groupName string, out io.Writer) (string, string, error) {
+		if isGroupRunning(state, groupName) {
+			return "skipped", "already running", nil
+		}
+		if err := startGroupIn(cfg, state, groupName, startOptions{out: out, env: env}); err != nil {
+			return "", "", err
+		}
+		return "started", describePorts(state.GetGroup(groupName)), nil
+	})
+}
+
+// loadConfig loads the grappler config, pointing at init when there is none
+func loadConfig() (*config.Config, error) {
 	cfg, err := config.Load(config.GetConfigPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load config (run 'grappler init' first): %w", err)
+	}
+	return cfg, err
+}
+
+// loadConfigAndState loads the config and the state
+func loadConfigAndState() (*config.Config, *config.State, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state, err := config.LoadState(config.GetStatePath())
 	if err != nil {
-		return fmt.Errorf("failed to load config (run 'grappler init' first): %w", err)
+		return nil, nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	return cfg, state, nil
+}
+
+// startGroup loads the config and state and starts a group (see startGroupIn)
+func startGroup(groupName string, opts startOptions) error {
+	cfg, state, err := loadConfigAndState()
+	if err != nil {
+		return err
+	}
+	return startGroupIn(cfg, state, groupName, opts)
+}
+
+// startGroupIn allocates ports, runs hooks and starts every service of a
+// group, recording it in state. It is safe to call for different groups in
+// parallel with a shared state.
+func startGroupIn(cfg *config.Config, state *config.State, groupName string, opts startOptions) error {
+	out := opts.out
+	if out == nil {
+		out = os.Stdout
 	}
 
 	// Find group
@@ -36,137 +185,380 @@ func runStart(cmd *cobra.Command, args []string) error {
 	if !exists {
 		return fmt.Errorf("group %q not found in config", groupName)
 	}
-
-	// Load state
-	state, err := config.LoadState(config.GetStatePath())
-	if err != nil {
-		return fmt.Errorf("failed to load state: %w", err)
+	if err := group.RepoConfigErr(); err != nil {
+		return fmt.Errorf("group %q: %w", groupName, err)
 	}
 
 	// Check if group is already running
-	groupState := state.GetGroup(groupName)
-	if groupState != nil && groupState.Running {
+	if isGroupRunning(state, groupName) {
 		return fmt.Errorf("group %q is already running", groupName)
 	}
+	if groupState := state.GetGroup(groupName); groupState != nil && groupState.Systemd {
+		return fmt.Errorf("group %q is managed by systemd (use systemctl --user, or 'grappler systemd uninstall %s')", groupName, groupName)
+	}
 
-	fmt.Printf("Starting group %q...\n", groupName)
+	fmt.Fprintf(out, "Starting group %q...\n", groupName)
 
 	// Allocate ports
-	allocator := ports.NewAllocator(state)
+	newState, err := reservePorts(cfg, state, groupName, group, opts.ports)
+	if err != nil {
+		return err
+	}
 
-	var backendPort, frontendPort int
-	if group.Backend != nil {
-		backendPort, err = allocator.AllocateBackendPort()
-		if err != nil {
-			return fmt.Errorf("failed to allocate backend port: %w", err)
+	newState.Env = opts.env
+	newState.Session = opts.session
+
+	// Release the reserved ports unless the group starts
+	started := false
+	defer func() {
+		if !started {
+			stopSidecars(out, state, groupName, newState)
+			releasePorts(out, state, groupName)
 		}
-		fmt.Printf("  Backend port:  %d\n", backendPort)
-	}
+	}()
 
-	if group.Frontend != nil {
-		frontendPort, err = allocator.AllocateFrontendPort()
-		if err != nil {
-			return fmt.Errorf("failed to allocate frontend port: %w", err)
+	for _, name := range []string{"backend", "frontend"} {
+		port := newState.Port(name)
+		if port == 0 {
+			continue
+		}
+		label := serviceTitle(name) + " port:"
+		if preferred := opts.ports[name]; preferred > 0 && preferred != port {
+			fmt.Fprintf(out, "  %-15s%d (%d is taken)\n", label, port, preferred)
+		} else {
+			fmt.Fprintf(out, "  %-15s%d\n", label, port)
 		}
-		fmt.Printf("  Frontend port: %d\n", frontendPort)
 	}
 
 	// Start processes
 	procMgr := process.NewManager(config.GetLogsDir())
-
-	newState := &config.GroupState{
-		BackendPort:  backendPort,
-		FrontendPort: frontendPort,
-		Running:      true,
+	launch := opts.launch
+	if launch == nil {
+		launch = func(service *config.Service, serviceName string, env []string) (int, []string, error) {
+			return procMgr.StartService(service, serviceName, groupName, env)
+		}
 	}
 
-	// Start backend
-	if group.Backend != nil {
-		fmt.Println("\nStarting backend...")
-		envVars := map[string]string{
-			"SERVER_PORT": strconv.Itoa(backendPort),
-		}
+	// Interpolate ${...} references now that ports are known
+	group, vars, err := resolveGroup(groupName, group, newState)
+	if err != nil {
+		return err
+	}
 
-		pid, err := procMgr.StartService(group.Backend, "backend", groupName, envVars)
+	// Build each service's environment up front so env errors fail fast
+	envs := make(map[string][]string)
+	for _, svc := range group.ServiceList() {
+		env, err := serviceEnv(vars, group, svc.Name)
 		if err != nil {
-			return fmt.Errorf("failed to start backend: %w", err)
+			return err
 		}
+		envs[svc.Name] = env.List()
+	}
 
-		newState.BackendPID = pid
-		fmt.Printf("✓ Backend started (PID: %d)\n", pid)
+	hooksEnv, err := groupEnv(vars, group)
+	if err != nil {
+		return err
 	}
 
-	// Start frontend
-	if group.Frontend != nil {
-		fmt.Println("\nStarting frontend...")
-		envVars := map[string]string{
-			"CONDUCTOR_PORT": strconv.Itoa(frontendPort),
+	// Sidecars come first: database servers among them are needed next
+	if err := startSidecars(out, procMgr, state, vars, group, newState); err != nil {
+		return err
+	}
+
+	for _, svc := range group.ServiceList() {
+		if err := prepareDataDir(out, vars, group, svc.Name); err != nil {
+			return err
 		}
+	}
+	if err := provisionDatabase(out, vars); err != nil {
+		return err
+	}
 
-		pid, err := procMgr.StartService(group.Frontend, "frontend", groupName, envVars)
-		if err != nil {
-			// If frontend fails, stop backend
-			if newState.BackendPID > 0 {
-				procMgr.StopProcess(newState.BackendPID)
+	// Start each run with fresh logs; hooks and services append to them
+	if err := resetGroupLogs(procMgr, group, groupName); err != nil {
+		return err
+	}
+
+	hooks := &hookRunner{
+		procMgr:    procMgr,
+		state:      state,
+		group:      group,
+		groupName:  groupName,
+		forceSetup: startForceSetup,
+		out:        out,
+	}
+
+	if err := hooks.runGroup(config.HookSetup, hooksEnv.List()); err != nil {
+		return hookFailed(out, err, procMgr.LogPath(groupName, groupHooksLog))
+	}
+	if err := hooks.runGroup(config.HookPreStart, hooksEnv.List()); err != nil {
+		return hookFailed(out, err, procMgr.LogPath(groupName, groupHooksLog))
+	}
+
+	// Start each service, stopping the ones already started if one fails
+	for _, svc := range group.ServiceList() {
+		if opts.skips(svc.Name) {
+			newState.SetStopped(svc.Name, true)
+			continue
+		}
+		if opts.session != "" {
+			if err := runStartHooks(hooks, svc.Service, svc.Name, envs[svc.Name]); err != nil {
+				return hookFailed(out, err, procMgr.LogPath(groupName, svc.Name))
 			}
-			return fmt.Errorf("failed to start frontend: %w", err)
+			continue
+		}
+		if err := startService(hooks, launch, newState, svc.Name, envs[svc.Name]); err != nil {
+			stopStartedServices(procMgr, newState)
+			return err
 		}
-
-		newState.FrontendPID = pid
-		fmt.Printf("✓ Frontend started (PID: %d)\n", pid)
 	}
 
 	// Save state
+	started = true
 	state.SetGroup(groupName, newState)
 	if err := state.Save(config.GetStatePath()); err != nil {
 		return fmt.Errorf("failed to save state: %w", err)
 	}
 
-	// Wait for services to be healthy
-	fmt.Println("\nWaiting for services to be healthy...")
-	healthChecker := process.NewHealthChecker()
+	if opts.session != "" {
+		fmt.Fprintf(out, "\n✓ Group %q is set up; its services run in %s\n", groupName, opts.session)
+		return nil
+	}
 
-	if newState.BackendPort > 0 {
-		if err := healthChecker.WaitForHealth(backendPort, 30*time.Second); err != nil {
-			fmt.Printf("⚠ Backend health check failed: %v\n", err)
-			fmt.Printf("  Check logs: ~/.grappler/logs/%s-backend.log\n", groupName)
-		} else {
-			fmt.Printf("✓ Backend healthy (http://localhost:%d)\n", backendPort)
+	// Wait for services to be healthy
+	fmt.Fprintln(out, "\nWaiting for services to be healthy...")
+	for _, svc := range group.ServiceList() {
+		if port := newState.Port(svc.Name); port > 0 && !opts.skips(svc.Name) {
+			waitHealthy(out, groupName, svc.Name, svc.Service.Health, port)
 		}
 	}
 
-	if newState.FrontendPort > 0 {
-		if err := healthChecker.WaitForHealth(frontendPort, 30*time.Second); err != nil {
-			fmt.Printf("⚠ Frontend health check failed: %v\n", err)
-			fmt.Printf("  Check logs: ~/.grappler/logs/%s-frontend.log\n", groupName)
-		} else {
-			fmt.Printf("✓ Frontend healthy (http://localhost:%d)\n", frontendPort)
+	// Run post-start hooks once services are up; a failure tears the group down
+	for _, svc := range group.ServiceList() {
+		if opts.skips(svc.Name) {
+			continue
 		}
+		if err := hooks.runService(config.HookPostStart, svc.Service, svc.Name, envs[svc.Name]); err != nil {
+			return abortStart(out, procMgr, state, groupName, newState, hookFailed(out, err, procMgr.LogPath(groupName, svc.Name)))
+		}
+	}
+	if err := hooks.runGroup(config.HookPostStart, hooksEnv.List()); err != nil {
+		return abortStart(out, procMgr, state, groupName, newState, hookFailed(out, err, procMgr.LogPath(groupName, groupHooksLog)))
 	}
 
 	// Print access info
-	fmt.Println("\n" + repeatString("=", 50))
-	fmt.Printf("Group %q is running\n", groupName)
+	fmt.Fprintln(out, "\n"+repeatString("=", 50))
+	fmt.Fprintf(out, "Group %q is running\n", groupName)
 
 	if newState.FrontendPort > 0 {
-		fmt.Printf("\nAccess frontend via conductor proxy:\n")
-		fmt.Printf("  http://%d.port.localhost:3000\n", frontendPort)
-		fmt.Printf("\nDirect access:\n")
+		fmt.Fprintf(out, "\nAccess frontend via conductor proxy:\n")
+		fmt.Fprintf(out, "  http://%d.port.localhost:3000\n", newState.FrontendPort)
+		fmt.Fprintf(out, "\nDirect access:\n")
 	}
 
 	if newState.BackendPort > 0 {
-		fmt.Printf("  Backend:  http://localhost:%d\n", backendPort)
+		fmt.Fprintf(out, "  Backend:  http://localhost:%d\n", newState.BackendPort)
 	}
 
 	if newState.FrontendPort > 0 {
-		fmt.Printf("  Frontend: http://localhost:%d\n", frontendPort)
+		fmt.Fprintf(out, "  Frontend: http://localhost:%d\n", newState.FrontendPort)
+	}
+
+	fmt.Fprintln(out, "\n"+repeatString("=", 50))
+
+	return nil
+}
+
+// portsMu serializes port allocation so groups started in parallel never
+// pick the same ports
+var portsMu sync.Mutex
+
+// reservePorts allocates a port for each service and sidecar of a group,
+// keeping the preferred ports where they are still free, and records them in
+// state so other starts skip them until the group is running
+func reservePorts(cfg *config.Config, state *config.State, groupName string, group *config.Group, preferred map[string]int) (*config.GroupState, error) {
+	portsMu.Lock()
+	defer portsMu.Unlock()
+
+	allocator := ports.NewAllocator(state, cfg.Ports)
+	newState := &config.GroupState{Running: true}
+
+	var err error
+	if group.Backend != nil {
+		newState.BackendPort, err = allocator.AllocateBackendPortPreferring(preferred["backend"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate backend port: %w", err)
+		}
+	}
+
+	if group.Frontend != nil {
+		newState.FrontendPort, err = allocator.AllocateFrontendPortPreferring(preferred["frontend"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate frontend port: %w", err)
+		}
+	}
+
+	if err := reserveSidecars(allocator, state, groupName, group, newState, preferred); err != nil {
+		stopSidecars(io.Discard, state, groupName, newState)
+		return nil, err
+	}
+
+	reserved := &config.GroupState{
+		BackendPort:  newState.BackendPort,
+		FrontendPort: newState.FrontendPort,
+	}
+	for name, sidecar := range newState.Sidecars {
+		reserved.SetSidecar(name, &config.SidecarState{Port: sidecar.Port, Shared: sidecar.Shared})
+	}
+	state.SetGroup(groupName, reserved)
+
+	return newState, nil
+}
+
+// releasePorts drops the port reservation of a group that failed to start
+func releasePorts(out io.Writer, state *config.State, groupName string) {
+	state.DeleteGroup(groupName)
+	if err := state.Save(config.GetStatePath()); err != nil {
+		fmt.Fprintf(out, "⚠ Failed to save state: %v\n", err)
+	}
+}
+
+// describePorts summarizes the allocated ports of a group
+func describePorts(groupState *config.GroupState) string {
+	ports := groupState.Ports()
+
+	var names []string
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s :%d", name, ports[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// startService runs a service's setup and pre-start hooks and launches it,
+// recording its PID and argv in groupState
+func startService(hooks *hookRunner, launch serviceLauncher, groupState *config.GroupState, serviceName string, env []string) error {
+	out := hooks.out
+	service := hooks.group.Service(serviceName)
+
+	fmt.Fprintf(out, "\nStarting %s...\n", serviceName)
+
+	if err := runStartHooks(hooks, service, serviceName, env); err != nil {
+		return hookFailed(out, err, hooks.procMgr.LogPath(hooks.groupName, serviceName))
+	}
+
+	pid, argv, err := launch(service, serviceName, env)
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", serviceName, err)
 	}
 
-	fmt.Println("\n" + repeatString("=", 50))
+	groupState.SetPID(serviceName, pid)
+	groupState.SetArgv(serviceName, argv)
+	fmt.Fprintf(out, "✓ %s started (PID: %d)\n", serviceTitle(serviceName), pid)
 
 	return nil
 }
 
+// waitHealthy waits for a service to answer HTTP requests on its port,
+// warning if it doesn't
+func waitHealthy(out io.Writer, groupName, serviceName string, health *config.HealthCheck, port int) {
+	path, timeout := "/", 30*time.Second
+	if health != nil {
+		if health.Disabled {
+			return
+		}
+		if health.Path != "" {
+			path = health.Path
+		}
+		if health.Timeout != "" {
+			d, err := time.ParseDuration(health.Timeout)
+			if err != nil {
+				fmt.Fprintf(out, "⚠ %s health.timeout: %v; waiting %s\n", serviceTitle(serviceName), err, timeout)
+			} else {
+				timeout = d
+			}
+		}
+	}
+
+	healthChecker := process.NewHealthChecker()
+	if err := healthChecker.WaitForPath(port, path, timeout); err != nil {
+		fmt.Fprintf(out, "⚠ %s health check failed: %v\n", serviceTitle(serviceName), err)
+		fmt.Fprintf(out, "  Check logs: %s\n", process.NewManager(config.GetLogsDir()).LogPath(groupName, serviceName))
+	} else {
+		fmt.Fprintf(out, "✓ %s healthy (http://localhost:%d)\n", serviceTitle(serviceName), port)
+	}
+}
+
+// serviceTitle returns a service name capitalized for messages
+func serviceTitle(serviceName string) string {
+	if serviceName == "" {
+		return ""
+	}
+	return strings.ToUpper(serviceName[:1]) + serviceName[1:]
+}
+
+// resetGroupLogs truncates the logs of every service and hook in a group
+func resetGroupLogs(procMgr *process.Manager, group *config.Group, groupName string) error {
+	if group.Backend != nil {
+		if err := procMgr.ResetLog(groupName, "backend"); err != nil {
+			return err
+		}
+	}
+	if group.Frontend != nil {
+		if err := procMgr.ResetLog(groupName, "frontend"); err != nil {
+			return err
+		}
+	}
+	if group.Hooks != nil {
+		if err := procMgr.ResetLog(groupName, groupHooksLog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStartHooks runs a service's setup and pre-start hooks
+func runStartHooks(hooks *hookRunner, service *config.Service, serviceName string, env []string) error {
+	if err := hooks.runService(config.HookSetup, service, serviceName, env); err != nil {
+		return err
+	}
+	return hooks.runService(config.HookPreStart, service, serviceName, env)
+}
+
+// hookFailed reports a failed hook and where its output was logged
+func hookFailed(out io.Writer, err error, logPath string) error {
+	fmt.Fprintf(out, "✗ %v\n", err)
+	fmt.Fprintf(out, "  Check logs: %s\n", logPath)
+	return err
+}
+
+// stopStartedServices stops any services already started for a group
+func stopStartedServices(procMgr *process.Manager, groupState *config.GroupState) {
+	if groupState.BackendPID > 0 {
+		procMgr.StopProcess(groupState.BackendPID)
+	}
+	if groupState.FrontendPID > 0 {
+		procMgr.StopProcess(groupState.FrontendPID)
+	}
+}
+
+// abortStart stops a partially started group and its sidecars and removes
+// it from state
+func abortStart(out io.Writer, procMgr *process.Manager, state *config.State, groupName string, groupState *config.GroupState, cause error) error {
+	stopStartedServices(procMgr, groupState)
+	stopSidecars(out, state, groupName, groupState)
+	state.DeleteGroup(groupName)
+	if err := state.Save(config.GetStatePath()); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return cause
+}
+
 func repeatString(s string, n int) string {
 	result := ""
 	for i := 0; i < n; i++ {
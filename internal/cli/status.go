@@ -2,37 +2,118 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/kris-hansen/grappler/internal/config"
 	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/kris-hansen/grappler/internal/systemd"
 	"github.com/kris-hansen/grappler/internal/worktree"
 	"github.com/spf13/cobra"
 )
 
+var (
+	statusSelectors []string
+	statusColumns   []string
+)
+
+// Columns status can show besides label values, by name
+var statusColumnHeaders = map[string]string{
+	"group":       "GROUP",
+	"backend":     "BACKEND PORT",
+	"frontend":    "FRONTEND PORT",
+	"status":      "STATUS",
+	"access":      "ACCESS",
+	"branch":      "BRANCH",
+	"description": "DESCRIPTION",
+	"labels":      "LABELS",
+}
+
+// Longest description shown in a status column
+const maxDescriptionWidth = 50
+
 // StatusCmd returns the status command
 func StatusCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "status",
+	cmd := &cobra.Command{
+		Use:   "status [group|pattern...]",
 		Short: "Show status of all groups",
-		Long:  `Displays the status of all configured groups including their ports and running state.`,
-		RunE:  runStatus,
+		Long: `Displays the status of all configured groups including their ports and running state.
+
+Groups can be narrowed by name, glob pattern or label selector, and -c prints a compact table of the chosen columns instead: group, backend, frontend, status, access, branch, description and labels, or any label key for that label's value.
+
+  grappler status -l owner=me
+  grappler status -l ticket=ERE-5326 -c group,status,access,pr`,
+		SilenceUsage: true,
+		RunE:         runStatus,
 	}
+	cmd.Flags().StringArrayVarP(&statusSelectors, "selector", "l", nil, "Select groups by label: key=value, key!=value or key (repeatable)")
+	cmd.Flags().StringSliceVarP(&statusColumns, "columns", "c", nil, "Print a table of these columns only (comma-separated)")
+	return cmd
+}
+
+// groupStatus is what status reports for one group
+type groupStatus struct {
+	name         string
+	group        *config.Group
+	state        *config.GroupState
+	backendPort  string
+	frontendPort string
+	status       string
+	access       string
+}
+
+// column returns the value of a status column
+func (g *groupStatus) column(name string) string {
+	switch name {
+	case "group":
+		return g.name
+	case "backend":
+		return g.backendPort
+	case "frontend":
+		return g.frontendPort
+	case "status":
+		return g.status
+	case "access":
+		return g.access
+	case "branch":
+		for _, svc := range g.group.ServiceList() {
+			if svc.Service.Branch != "" {
+				return svc.Service.Branch
+			}
+		}
+	case "description":
+		if runes := []rune(g.group.Description); len(runes) > maxDescriptionWidth {
+			return string(runes[:maxDescriptionWidth-1]) + "…"
+		}
+		if g.group.Description != "" {
+			return g.group.Description
+		}
+	case "labels":
+		if len(g.group.Labels) > 0 {
+			return formatLabels(g.group.Labels)
+		}
+	default:
+		if value, ok := g.group.Labels[name]; ok {
+			return value
+		}
+	}
+	return "-"
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
 	// Load config
-	cfg, err := config.Load(config.GetConfigPath())
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to load config (run 'grappler init' first): %w", err)
+		return err
 	}
-	if pruneMissingDirectories(cfg) {
-		if err := cfg.Save(config.GetConfigPath()); err != nil {
+	if pruned := pruneMissingDirectories(cfg); len(pruned) > 0 {
+		if err := savePruned(pruned); err != nil {
 			return fmt.Errorf("failed to save config: %w", err)
 		}
 	}
@@ -43,43 +124,83 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
+	columns := make([]string, len(statusColumns))
+	for i, column := range statusColumns {
+		columns[i] = strings.TrimSpace(column)
+		if columns[i] == "" {
+			return fmt.Errorf("invalid column list %q", strings.Join(statusColumns, ","))
+		}
+	}
+
+	names := configGroupNames(cfg)
+	sort.Strings(names)
+	filtered := len(args) > 0 || len(statusSelectors) > 0
+	if filtered && len(names) > 0 {
+		opts := bulkOptions{selectors: statusSelectors}
+		if names, err = opts.selectGroups(cfg, args, names, func(string) bool { return true }); err != nil {
+			return err
+		}
+	}
+
 	procMgr := process.NewManager(config.GetLogsDir())
 	runningPorts := make(map[string][]servicePort)
 
-	fmt.Println("Grappler Status")
-	fmt.Println(repeatString("=", 80))
+	pending := state.ResumeGroups()
 
-	if len(cfg.Groups) == 0 {
-		fmt.Println("No groups configured")
-		return nil
-	}
+	var groups []*groupStatus
+	for _, name := range names {
+		group := resolveDirectories(name, cfg.Groups[name])
+		groupState := state.GetGroup(name)
 
-	// Print header
-	fmt.Printf("%-20s %-15s %-15s %-10s %s\n", "GROUP", "BACKEND PORT", "FRONTEND PORT", "STATUS", "ACCESS")
-	fmt.Println(repeatString("-", 80))
+		g := &groupStatus{
+			name:         name,
+			group:        group,
+			state:        groupState,
+			backendPort:  "-",
+			frontendPort: "-",
+			status:       "stopped",
+			access:       "-",
+		}
+		groups = append(groups, g)
 
-	for name, group := range cfg.Groups {
-		groupState := state.GetGroup(name)
+		if pending[name] != nil {
+			g.status = "resumable"
+		}
 
-		backendPort := "-"
-		frontendPort := "-"
-		status := "stopped"
-		access := "-"
+		if groupState != nil && groupState.Systemd {
+			// Groups run by systemd units: report the target's state
+			g.status = "systemd"
+			if groupState.BackendPort > 0 {
+				g.backendPort = fmt.Sprintf("%d", groupState.BackendPort)
+				g.access = fmt.Sprintf("http://localhost:%d", groupState.BackendPort)
+			}
+			if groupState.FrontendPort > 0 {
+				g.frontendPort = fmt.Sprintf("%d", groupState.FrontendPort)
+				g.access = fmt.Sprintf("http://%d.port.localhost:3000", groupState.FrontendPort)
+			}
+		}
 
 		if groupState != nil && groupState.Running {
 			// Verify processes are actually running
 			backendRunning := procMgr.IsProcessRunning(groupState.BackendPID)
 			frontendRunning := procMgr.IsProcessRunning(groupState.FrontendPID)
 
-			if !backendRunning && !frontendRunning {
-				// Both stopped - clean up state
-				status = "stopped"
+			// Services stopped with 'grappler stop <group> <service>' keep the group
+			stoppedOnPurpose := groupState.IsStopped("backend") || groupState.IsStopped("frontend")
+
+			// Services of multiplexer sessions run until 'grappler stop'
+			inSession := groupState.Session != ""
+
+			if !backendRunning && !frontendRunning && !stoppedOnPurpose && !inSession {
+				// Both stopped - clean up state, and the sidecars left behind
+				g.status = "stopped"
+				stopSidecars(io.Discard, state, name, groupState)
 				state.DeleteGroup(name)
 			} else {
-				status = "running"
+				g.status = "running"
 
 				if groupState.BackendPort > 0 {
-					backendPort = fmt.Sprintf("%d", groupState.BackendPort)
+					g.backendPort = fmt.Sprintf("%d", groupState.BackendPort)
 					if group.Backend != nil {
 						runningPorts[group.Backend.Directory] = append(runningPorts[group.Backend.Directory], servicePort{
 							Group: name,
@@ -90,8 +211,8 @@ func runStatus(cmd *cobra.Command, args []string) error {
 				}
 
 				if groupState.FrontendPort > 0 {
-					frontendPort = fmt.Sprintf("%d", groupState.FrontendPort)
-					access = fmt.Sprintf("http://%d.port.localhost:3000", groupState.FrontendPort)
+					g.frontendPort = fmt.Sprintf("%d", groupState.FrontendPort)
+					g.access = fmt.Sprintf("http://%d.port.localhost:3000", groupState.FrontendPort)
 					if group.Frontend != nil {
 						runningPorts[group.Frontend.Directory] = append(runningPorts[group.Frontend.Directory], servicePort{
 							Group: name,
@@ -100,27 +221,70 @@ func runStatus(cmd *cobra.Command, args []string) error {
 						})
 					}
 				} else if groupState.BackendPort > 0 {
-					access = fmt.Sprintf("http://localhost:%d", groupState.BackendPort)
+					g.access = fmt.Sprintf("http://localhost:%d", groupState.BackendPort)
 				}
 			}
 		}
+	}
+
+	// Save state if we cleaned up any stopped groups
+	if err := state.Save(config.GetStatePath()); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	if len(columns) > 0 {
+		printStatusColumns(groups, columns)
+		return nil
+	}
+
+	fmt.Println("Grappler Status")
+	fmt.Println(repeatString("=", 80))
+
+	if len(groups) == 0 {
+		fmt.Println("No groups configured")
+		return nil
+	}
+
+	// Print header
+	fmt.Printf("%-20s %-15s %-15s %-10s %s\n", "GROUP", "BACKEND PORT", "FRONTEND PORT", "STATUS", "ACCESS")
+	fmt.Println(repeatString("-", 80))
 
+	for _, g := range groups {
 		// Print group info
-		fmt.Printf("%-20s %-15s %-15s %-10s %s\n", name, backendPort, frontendPort, status, access)
+		fmt.Printf("%-20s %-15s %-15s %-10s %s\n", g.name, g.backendPort, g.frontendPort, g.status, g.access)
+		if g.group.Description != "" {
+			fmt.Printf("  %s\n", g.group.Description)
+		}
+		if len(g.group.Labels) > 0 {
+			fmt.Printf("  Labels:   %s\n", formatLabels(g.group.Labels))
+		}
+		if g.status == "systemd" {
+			target := systemd.TargetName(profileQualified(g.name))
+			fmt.Printf("  Units:    %s (%s)\n", target, systemd.ActiveState(target))
+		}
 
-		// Show branch info
-		if group.Backend != nil {
-			fmt.Printf("  Backend:  %s\n", group.Backend.Branch)
+		// Show branch info, and the executed command for running services
+		if g.group.Backend != nil {
+			fmt.Printf("  Backend:  %s%s\n", g.group.Backend.Branch, serviceStopped(g.state, "backend", g.status))
+			printArgv(g.state, "backend", g.status)
 		}
-		if group.Frontend != nil {
-			fmt.Printf("  Frontend: %s\n", group.Frontend.Branch)
+		if g.group.Frontend != nil {
+			fmt.Printf("  Frontend: %s%s\n", g.group.Frontend.Branch, serviceStopped(g.state, "frontend", g.status))
+			printArgv(g.state, "frontend", g.status)
+		}
+		if g.status == "running" && len(g.state.Sidecars) > 0 {
+			fmt.Printf("  Sidecars: %s\n", describeSidecars(g.state))
 		}
 		fmt.Println()
 	}
 
-	// Save state if we cleaned up any stopped groups
-	if err := state.Save(config.GetStatePath()); err != nil {
-		return fmt.Errorf("failed to save state: %w", err)
+	if len(pending) > 0 {
+		fmt.Printf("%d group(s) were running before the system restarted; run 'grappler resume' to start them again\n\n", len(pending))
+	}
+
+	// The port map covers every worktree, so it only goes with the full view
+	if filtered {
+		return nil
 	}
 
 	fmt.Println(repeatString("=", 80))
@@ -149,6 +313,65 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printStatusColumns prints a table of the chosen columns, sized to fit
+func printStatusColumns(groups []*groupStatus, columns []string) {
+	rows := make([][]string, 0, len(groups)+1)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		if h, ok := statusColumnHeaders[column]; ok {
+			header[i] = h
+		} else {
+			header[i] = strings.ToUpper(column)
+		}
+	}
+	rows = append(rows, header)
+	for _, g := range groups {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = g.column(column)
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(columns))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	for _, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			if i == len(row)-1 {
+				line.WriteString(cell)
+				break
+			}
+			line.WriteString(cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2))
+		}
+		fmt.Println(line.String())
+	}
+}
+
+// printArgv prints the argv a running service was started with
+func printArgv(groupState *config.GroupState, serviceName, status string) {
+	if status != "running" || groupState.IsStopped(serviceName) {
+		return
+	}
+	if svc := groupState.Service(serviceName); svc != nil && len(svc.Argv) > 0 {
+		fmt.Printf("    $ %s\n", process.QuoteArgv(svc.Argv))
+	}
+}
+
+// serviceStopped returns a marker for a service stopped on its own in a
+// running group
+func serviceStopped(groupState *config.GroupState, serviceName, status string) string {
+	if status == "running" && groupState.IsStopped(serviceName) {
+		return " (stopped)"
+	}
+	return ""
+}
+
 type servicePort struct {
 	Group   string
 	Role    string
@@ -159,7 +382,8 @@ type servicePort struct {
 func scanRepoWorktrees(cfg *config.Config) (map[string][]worktree.Worktree, error) {
 	repoDirs := make(map[string]string)
 
-	for _, group := range cfg.Groups {
+	for name, group := range cfg.Groups {
+		group = resolveDirectories(name, group)
 		if group.Backend != nil {
 			commonDir, err := worktree.GetCommonDir(group.Backend.Directory)
 			if err != nil {
@@ -193,39 +417,68 @@ func scanRepoWorktrees(cfg *config.Config) (map[string][]worktree.Worktree, erro
 	return repoWorktrees, nil
 }
 
-func pruneMissingDirectories(cfg *config.Config) bool {
-	updated := false
+// prunedService names a service whose worktree is gone, or a whole group
+// if service is empty
+type prunedService struct {
+	group   string
+	service string
+}
+
+// pruneMissingDirectories removes services whose worktree directories no
+// longer exist from cfg, and groups left without services, returning what
+// it removed
+func pruneMissingDirectories(cfg *config.Config) []prunedService {
+	var pruned []prunedService
 
 	for name, group := range cfg.Groups {
-		if group.Backend != nil {
-			if _, err := os.Stat(group.Backend.Directory); err != nil {
-				if os.IsNotExist(err) {
-					group.Backend = nil
-					updated = true
-				} else {
-					fmt.Printf("Warning: failed to stat backend directory for %s: %v\n", name, err)
-				}
-			}
+		// Stat resolved directories, but prune services from the config as written
+		vars, err := config.NewVars(name, group, nil)
+		if err != nil {
+			fmt.Printf("Warning: failed to resolve directories for %s: %v\n", name, err)
+			continue
 		}
 
-		if group.Frontend != nil {
-			if _, err := os.Stat(group.Frontend.Directory); err != nil {
+		var removed []prunedService
+		for _, svc := range group.ServiceList() {
+			if _, err := os.Stat(vars.Directory(svc.Name)); err != nil {
 				if os.IsNotExist(err) {
-					group.Frontend = nil
-					updated = true
+					group.SetService(svc.Name, nil)
+					removed = append(removed, prunedService{group: name, service: svc.Name})
 				} else {
-					fmt.Printf("Warning: failed to stat frontend directory for %s: %v\n", name, err)
+					fmt.Printf("Warning: failed to stat %s directory for %s: %v\n", svc.Name, name, err)
 				}
 			}
 		}
 
 		if group.Backend == nil && group.Frontend == nil {
 			delete(cfg.Groups, name)
-			updated = true
+			removed = []prunedService{{group: name}}
 		}
+		pruned = append(pruned, removed...)
+	}
+
+	return pruned
+}
+
+// savePruned removes pruned services and groups from the config file,
+// leaving the rest of it as written
+func savePruned(pruned []prunedService) error {
+	doc, err := config.LoadDocument(config.GetConfigPath())
+	if err != nil {
+		return err
 	}
 
-	return updated
+	for _, p := range pruned {
+		if p.service == "" {
+			if doc.HasGroup(p.group) {
+				doc.RemoveGroup(p.group)
+			}
+			continue
+		}
+		// Services a group inherits from a template aren't in its entry
+		doc.RemoveService(p.group, p.service)
+	}
+	return doc.Save()
 }
 
 func printWorktreePortMap(repoWorktrees map[string][]worktree.Worktree, runningPorts map[string][]servicePort) {
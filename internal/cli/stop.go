@@ -2,69 +2,209 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/kris-hansen/grappler/internal/config"
 	"github.com/kris-hansen/grappler/internal/process"
 	"github.com/spf13/cobra"
 )
 
+var stopBulk bulkOptions
+
 // StopCmd returns the stop command
 func StopCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "stop <group>",
-		Short: "Stop a running worktree group",
-		Long:  `Stops the backend and frontend services for a running worktree group and releases ports.`,
-		Args:  cobra.ExactArgs(1),
-		RunE:  runStop,
+	cmd := &cobra.Command{
+		Use:   "stop [group...]",
+		Short: "Stop running worktree groups",
+		Long: `Stops the backend and frontend services for running worktree groups and releases ports.
+
+With a group and one of its services ('grappler stop main backend'), stops just that service; its port stays reserved for the group.
+
+Groups are selected like for 'grappler start'; patterns, labels and --all only match running groups.`,
+		Args:         cobra.ArbitraryArgs,
+		SilenceUsage: true,
+		RunE:         runStop,
 	}
+
+	addBulkFlags(cmd, &stopBulk)
+
+	return cmd
 }
 
 func runStop(cmd *cobra.Command, args []string) error {
-	groupName := args[0]
-
 	// Load state
 	state, err := config.LoadState(config.GetStatePath())
 	if err != nil {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	// Find group state
-	groupState := state.GetGroup(groupName)
-	if groupState == nil || !groupState.Running {
-		return fmt.Errorf("group %q is not running", groupName)
+	// Groups removed from config can still be stopped by name
+	cfg, err := config.Load(config.GetConfigPath())
+	if err != nil {
+		cfg = &config.Config{}
 	}
 
-	fmt.Printf("Stopping group %q...\n", groupName)
-
-	procMgr := process.NewManager(config.GetLogsDir())
+	if serviceTarget(cfg, &stopBulk, args) {
+		return stopGroupService(cfg, state, args[0], args[1], os.Stdout)
+	}
+	if stopBulk.single(args) {
+		return stopGroup(state, args[0], os.Stdout)
+	}
 
-	// Stop backend
-	if groupState.BackendPID > 0 {
-		fmt.Printf("Stopping backend (PID: %d)...\n", groupState.BackendPID)
-		if err := procMgr.StopProcess(groupState.BackendPID); err != nil {
-			fmt.Printf("⚠ Failed to stop backend: %v\n", err)
-		} else {
-			fmt.Println("✓ Backend stopped")
+	candidates := configGroupNames(cfg)
+	for name, groupState := range state.GroupStates() {
+		if groupState.Running && cfg.Groups[name] == nil {
+			candidates = append(candidates, name)
 		}
 	}
 
-	// Stop frontend
-	if groupState.FrontendPID > 0 {
-		fmt.Printf("Stopping frontend (PID: %d)...\n", groupState.FrontendPID)
-		if err := procMgr.StopProcess(groupState.FrontendPID); err != nil {
-			fmt.Printf("⚠ Failed to stop frontend: %v\n", err)
-		} else {
-			fmt.Println("✓ Frontend stopped")
+	names, err := stopBulk.selectGroups(cfg, args, candidates, func(name string) bool {
+		return isGroupRunning(state, name)
+	})
+	if err != nil {
+		return err
+	}
+
+	return runBulk(names, stopBulk.parallel, func(groupName string, out io.Writer) (string, string, error) {
+		if !isGroupRunning(state, groupName) {
+			return "skipped", "not running", nil
+		}
+		if err := stopGroup(state, groupName, out); err != nil {
+			return "", "", err
 		}
+		return "stopped", "", nil
+	})
+}
+
+// stopGroup stops a running group and removes it from state
+func stopGroup(state *config.State, groupName string, out io.Writer) error {
+	// Find group state
+	groupState := state.GetGroup(groupName)
+	if groupState == nil || !groupState.Running {
+		return fmt.Errorf("group %q is not running", groupName)
 	}
 
+	fmt.Fprintf(out, "Stopping group %q...\n", groupName)
+
+	procMgr := process.NewManager(config.GetLogsDir())
+	stopGroupServices(procMgr, state, groupName, groupState, procMgr.StopProcess, out)
+
 	// Remove from state
 	state.DeleteGroup(groupName)
 	if err := state.Save(config.GetStatePath()); err != nil {
 		return fmt.Errorf("failed to save state: %w", err)
 	}
 
-	fmt.Printf("\n✓ Group %q stopped\n", groupName)
+	fmt.Fprintf(out, "\n✓ Group %q stopped\n", groupName)
 
 	return nil
 }
+
+// stopGroupServices runs the stop hooks of a group and stops each of its
+// running services with kill, then its sidecars, leaving the state entry to
+// the caller
+func stopGroupServices(procMgr *process.Manager, state *config.State, groupName string, groupState *config.GroupState, kill func(pid int) error, out io.Writer) {
+	hooks, vars := stopHooks(procMgr, state, groupName, groupState, out)
+
+	hooksEnv := stopGroupEnv(out, vars, hooks.group)
+	warnHook(out, hooks.runGroup(config.HookPreStop, hooksEnv))
+
+	for _, name := range groupState.ServiceNames() {
+		if pid := groupState.PID(name); pid > 0 {
+			stopService(hooks, vars, name, pid, kill)
+		}
+	}
+	if groupState.Session != "" {
+		killSession(out, groupState.Session, groupName)
+	}
+
+	warnHook(out, hooks.runGroup(config.HookPostStop, hooksEnv))
+	removeEphemeralData(out, groupName, hooks.group)
+	stopSidecars(out, state, groupName, groupState)
+}
+
+// stopHooks returns a hook runner and variables for stopping services of a
+// group, resolving its config as far as possible
+func stopHooks(procMgr *process.Manager, state *config.State, groupName string, groupState *config.GroupState, out io.Writer) (*hookRunner, *config.Vars) {
+	// Hooks are optional on stop: a group removed from config can still be stopped
+	group := &config.Group{}
+	if cfg, err := config.Load(config.GetConfigPath()); err == nil && cfg.Groups[groupName] != nil {
+		group = cfg.Groups[groupName]
+	}
+
+	vars, err := config.NewVars(groupName, group, groupState.Ports())
+	if err != nil {
+		fmt.Fprintf(out, "⚠ Failed to resolve group config, running hooks unresolved: %v\n", err)
+		vars, _ = config.NewVars(groupName, &config.Group{}, nil)
+	} else if resolved, err := vars.ResolveGroup(group); err == nil {
+		group = resolved
+	} else {
+		fmt.Fprintf(out, "⚠ Failed to resolve group config, running hooks unresolved: %v\n", err)
+	}
+	vars.Env = groupState.Env
+
+	hooks := &hookRunner{
+		procMgr:   procMgr,
+		state:     state,
+		group:     group,
+		groupName: groupName,
+		out:       out,
+	}
+
+	return hooks, vars
+}
+
+// stopService runs a service's stop hooks around stopping its process
+func stopService(hooks *hookRunner, vars *config.Vars, serviceName string, pid int, kill func(pid int) error) {
+	out := hooks.out
+	service := hooks.group.Service(serviceName)
+
+	env := stopEnv(out, vars, hooks.group, serviceName)
+	warnHook(out, hooks.runService(config.HookPreStop, service, serviceName, env))
+
+	fmt.Fprintf(out, "Stopping %s (PID: %d)...\n", serviceName, pid)
+	if err := kill(pid); err != nil {
+		fmt.Fprintf(out, "⚠ Failed to stop %s: %v\n", serviceName, err)
+	} else {
+		fmt.Fprintf(out, "✓ %s stopped\n", serviceTitle(serviceName))
+	}
+
+	warnHook(out, hooks.runService(config.HookPostStop, service, serviceName, env))
+}
+
+// stopEnv returns the env for a service's stop hooks, warning and falling
+// back to the host env if it can't be fully built
+func stopEnv(out io.Writer, vars *config.Vars, group *config.Group, serviceName string) []string {
+	if group.Service(serviceName) == nil {
+		return nil
+	}
+
+	env, err := serviceEnv(vars, group, serviceName)
+	if err != nil {
+		fmt.Fprintf(out, "⚠ %v\n", err)
+		return os.Environ()
+	}
+	return env.List()
+}
+
+// stopGroupEnv returns the env for group stop hooks, like stopEnv
+func stopGroupEnv(out io.Writer, vars *config.Vars, group *config.Group) []string {
+	if group.Hooks == nil {
+		return nil
+	}
+
+	env, err := groupEnv(vars, group)
+	if err != nil {
+		fmt.Fprintf(out, "⚠ %v\n", err)
+		return os.Environ()
+	}
+	return env.List()
+}
+
+// warnHook reports a failed stop hook without aborting the stop
+func warnHook(out io.Writer, err error) {
+	if err != nil {
+		fmt.Fprintf(out, "⚠ %v\n", err)
+	}
+}
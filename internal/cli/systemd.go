@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/kris-hansen/grappler/internal/systemd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	systemdOutput string
+	systemdNow    bool
+)
+
+// SystemdCmd returns the systemd command
+func SystemdCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "systemd",
+		Short: "Run groups under systemd user units",
+		Long: `Generates systemd user units for a group: a service unit per service and a target per group. The units carry the environment, working directory, ports, hooks and start order grappler would use.
+
+Installed groups keep their ports reserved, so grappler never hands them out to other groups. Manage them with 'systemctl --user' (e.g. 'systemctl --user restart grappler-main.target'); 'grappler status' reports their state.`,
+	}
+
+	generateCmd := &cobra.Command{
+		Use:          "generate <group>",
+		Short:        "Print the units for a group",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runSystemdGenerate,
+	}
+	generateCmd.Flags().StringVarP(&systemdOutput, "output", "o", "", "Write the units to this directory instead of printing them")
+
+	installCmd := &cobra.Command{
+		Use:          "install <group>",
+		Short:        "Install and enable the units for a group",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runSystemdInstall,
+	}
+	installCmd.Flags().BoolVar(&systemdNow, "now", false, "Start the group's target after enabling it")
+
+	uninstallCmd := &cobra.Command{
+		Use:          "uninstall <group>",
+		Short:        "Stop, disable and remove the units for a group",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runSystemdUninstall,
+	}
+
+	cmd.AddCommand(generateCmd, installCmd, uninstallCmd)
+	return cmd
+}
+
+func runSystemdGenerate(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+
+	cfg, state, err := loadConfigAndState()
+	if err != nil {
+		return err
+	}
+
+	group, exists := cfg.Groups[groupName]
+	if !exists {
+		return fmt.Errorf("group %q not found in config", groupName)
+	}
+
+	groupState := state.GetGroup(groupName)
+	if groupState == nil || (!groupState.Running && !groupState.Systemd) {
+		groupState, err = provisionalPorts(cfg, state, group)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "⚠ Ports are provisional; 'grappler systemd install' reserves them")
+	}
+
+	specs, skipped, err := systemdSpecs(groupName, group, groupState, false)
+	if err != nil {
+		return err
+	}
+	units := systemd.GroupUnits(profileQualified(groupName), specs)
+
+	if systemdOutput != "" {
+		if err := os.MkdirAll(systemdOutput, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		for _, unit := range units {
+			path := filepath.Join(systemdOutput, unit.Name)
+			if err := os.WriteFile(path, []byte(unit.Content), 0644); err != nil {
+				return fmt.Errorf("failed to write unit %s: %w", unit.Name, err)
+			}
+			fmt.Printf("✓ Wrote %s\n", path)
+		}
+	} else {
+		for i, unit := range units {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("### %s\n%s", unit.Name, unit.Content)
+		}
+	}
+
+	warnSkipped(skipped)
+	return nil
+}
+
+func runSystemdInstall(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+
+	cfg, state, err := loadConfigAndState()
+	if err != nil {
+		return err
+	}
+
+	group, exists := cfg.Groups[groupName]
+	if !exists {
+		return fmt.Errorf("group %q not found in config", groupName)
+	}
+
+	if isGroupRunning(state, groupName) {
+		return fmt.Errorf("group %q is running under grappler; stop it first", groupName)
+	}
+
+	// Reserve ports for the units, keeping them across reinstalls
+	groupState := state.GetGroup(groupName)
+	if groupState == nil || !groupState.Systemd {
+		reserved, err := reservePorts(cfg, state, groupName, group, nil)
+		if err != nil {
+			return err
+		}
+		groupState = &config.GroupState{
+			BackendPort:  reserved.BackendPort,
+			FrontendPort: reserved.FrontendPort,
+			Systemd:      true,
+		}
+	}
+
+	specs, skipped, err := systemdSpecs(groupName, group, groupState, true)
+	if err != nil {
+		state.DeleteGroup(groupName)
+		return err
+	}
+
+	procMgr := process.NewManager(config.GetLogsDir())
+	for _, spec := range specs {
+		if err := procMgr.ResetLog(groupName, spec.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, unit := range systemd.GroupUnits(profileQualified(groupName), specs) {
+		path, err := systemd.InstallUnit(unit.Name, unit.Content)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Wrote %s\n", path)
+	}
+
+	state.SetGroup(groupName, groupState)
+	if err := state.Save(config.GetStatePath()); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	target := systemd.TargetName(profileQualified(groupName))
+	if err := systemd.Systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	enable := []string{"enable", target}
+	if systemdNow {
+		enable = []string{"enable", "--now", target}
+	}
+	if err := systemd.Systemctl(enable...); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Enabled %s (%s)\n", target, describePorts(groupState))
+	if !systemdNow {
+		fmt.Printf("\nStart it with: systemctl --user start %s\n", target)
+	}
+
+	warnSkipped(skipped)
+	return nil
+}
+
+func runSystemdUninstall(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	groupState := state.GetGroup(groupName)
+	if groupState == nil || !groupState.Systemd {
+		return fmt.Errorf("group %q is not installed as systemd units", groupName)
+	}
+
+	target := systemd.TargetName(profileQualified(groupName))
+	if err := systemd.Systemctl("disable", "--now", target); err != nil {
+		fmt.Printf("⚠ %v\n", err)
+	}
+
+	for _, name := range groupState.ServiceNames() {
+		if err := systemd.RemoveUnit(systemd.ServiceName(profileQualified(groupName), name)); err != nil {
+			return err
+		}
+	}
+	if err := systemd.RemoveUnit(target); err != nil {
+		return err
+	}
+
+	if err := systemd.Systemctl("daemon-reload"); err != nil {
+		fmt.Printf("⚠ %v\n", err)
+	}
+
+	state.DeleteGroup(groupName)
+	if err := state.Save(config.GetStatePath()); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	fmt.Printf("✓ Removed the units for group %q\n", groupName)
+	return nil
+}
+
+// systemdSpecs resolves a group against the ports in groupState and
+// describes each of its services for unit generation. It also returns the
+// parts of the group that units can't express. With provision, the group's
+// database and data directories are set up for the units too.
+func systemdSpecs(groupName string, group *config.Group, groupState *config.GroupState, provision bool) ([]systemd.ServiceSpec, []string, error) {
+	if len(group.Sidecars) > 0 {
+		return nil, nil, fmt.Errorf("group %q has sidecars, which systemd units don't run; start it with 'grappler start'", groupName)
+	}
+
+	resolved, vars, err := resolveGroup(groupName, group, groupState)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var skipped []string
+	for _, stage := range []string{config.HookSetup, config.HookPreStart, config.HookPostStart, config.HookPreStop, config.HookPostStop} {
+		if len(resolved.Hooks.Commands(stage)) > 0 {
+			skipped = append(skipped, fmt.Sprintf("group %s hooks", stage))
+		}
+	}
+
+	if provision {
+		if err := provisionDatabase(os.Stdout, vars); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	procMgr := process.NewManager(config.GetLogsDir())
+	var specs []systemd.ServiceSpec
+	var started []string
+
+	for _, svc := range resolved.ServiceList() {
+		env, err := serviceEnv(vars, resolved, svc.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if provision {
+			if err := prepareDataDir(os.Stdout, vars, resolved, svc.Name); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		argv, envList, err := process.ResolveCommand(svc.Service, env.List())
+		if err != nil {
+			return nil, nil, fmt.Errorf("group %q: %s: %w", groupName, svc.Name, err)
+		}
+
+		unitEnv := serviceUnitEnv(env)
+		// Leading NAME=value words of the command are appended to its env
+		for _, entry := range envList[len(env.List()):] {
+			if key, value, ok := strings.Cut(entry, "="); ok {
+				unitEnv[key] = value
+			}
+		}
+
+		shell := lookPath(process.HookShell(svc.Service))
+		hooks := func(stage string) [][]string {
+			var commands [][]string
+			for _, command := range svc.Service.Hooks.Commands(stage) {
+				commands = append(commands, []string{shell, "-c", command})
+			}
+			return commands
+		}
+
+		if len(svc.Service.Hooks.Commands(config.HookSetup)) > 0 {
+			skipped = append(skipped, fmt.Sprintf("%s setup hooks", svc.Name))
+		}
+
+		argv[0] = lookPath(argv[0])
+		specs = append(specs, systemd.ServiceSpec{
+			Name:          svc.Name,
+			Dir:           svc.Service.Directory,
+			Argv:          argv,
+			Env:           unitEnv,
+			LogPath:       procMgr.LogPath(groupName, svc.Name),
+			After:         append([]string(nil), started...),
+			ExecStartPre:  hooks(config.HookPreStart),
+			ExecStartPost: hooks(config.HookPostStart),
+			ExecStop:      hooks(config.HookPreStop),
+			ExecStopPost:  hooks(config.HookPostStop),
+		})
+		started = append(started, svc.Name)
+	}
+
+	return specs, skipped, nil
+}
+
+// serviceUnitEnv returns the variables of a service env that grappler sets
+// on top of the host env, plus the host PATH so the unit finds its tools.
+// The rest of the host env is left to the user's service manager.
+func serviceUnitEnv(env *process.Env) map[string]string {
+	vars := make(map[string]string)
+	for _, v := range env.Vars() {
+		if v.Source != process.SourceHost || v.Key == "PATH" {
+			vars[v.Key] = v.Value
+		}
+	}
+	return vars
+}
+
+// lookPath resolves a command name to an absolute path, since systemd
+// doesn't search the user's PATH
+func lookPath(name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+	return name
+}
+
+// warnSkipped reports parts of a group the units don't include
+func warnSkipped(skipped []string) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "⚠ Not included in the units: %s (run them with 'grappler start' once, or by hand)\n", strings.Join(skipped, ", "))
+}
@@ -0,0 +1,292 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+	"github.com/spf13/cobra"
+)
+
+// UpCmd returns the up command
+func UpCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "up <group>",
+		Short: "Run a group in the foreground with combined output",
+		Long: `Starts a group like 'grappler start', but stays in the foreground and streams the output of every service, prefixed with the service name.
+
+Press Ctrl-C to stop all services gracefully (running stop hooks); press it again to force-kill them. The group is removed from state when grappler exits.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runUp,
+	}
+
+	cmd.Flags().BoolVar(&startForceSetup, "setup", false, "Run setup hooks again even if they already completed")
+	addEnvFlag(cmd)
+
+	return cmd
+}
+
+// serviceExit reports that a service run by up exited
+type serviceExit struct {
+	name string
+	err  error
+}
+
+func runUp(cmd *cobra.Command, args []string) error {
+	groupName := args[0]
+	procMgr := process.NewManager(config.GetLogsDir())
+	output := newPrefixOutput(os.Stdout)
+
+	// Catch interrupts from the start, so Ctrl-C during startup doesn't
+	// leave services behind; services run in their own process groups
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	// One slot per service, so no exit blocks if up returns early
+	exited := make(chan serviceExit, 2)
+	running := 0
+	var launched []int
+	launch := func(service *config.Service, serviceName string, env []string) (int, []string, error) {
+		pid, argv, done, err := procMgr.RunService(service, serviceName, groupName, env, output.writer(serviceName))
+		if err != nil {
+			return 0, nil, err
+		}
+		running++
+		launched = append(launched, pid)
+		go func() {
+			exited <- serviceExit{name: serviceName, err: <-done}
+		}()
+		return pid, argv, nil
+	}
+
+	env, err := parseEnvOverrides(startEnv)
+	if err != nil {
+		return err
+	}
+
+	if err := startGroup(groupName, startOptions{launch: launch, env: env}); err != nil {
+		stopLaunched(procMgr, launched, exited, output)
+		return err
+	}
+
+	fmt.Println("\nAttached to service output. Press Ctrl-C to stop.")
+
+	stopping := false
+	for running > 0 {
+		select {
+		case exit := <-exited:
+			running--
+			output.flush(exit.name)
+			fmt.Printf("%s %s\n", output.prefix(exit.name), describeExit(exit.err))
+		case <-signals:
+			if stopping {
+				fmt.Println("\nForce-killing services...")
+				killGroup(procMgr, groupName, syscall.SIGKILL)
+				continue
+			}
+
+			stopping = true
+			fmt.Println("\nGracefully stopping... (press Ctrl-C again to force)")
+			go stopUpGroup(procMgr, groupName)
+		}
+	}
+
+	// Every service has exited; remove ephemeral data, stop the sidecars
+	// (if stopping didn't already) and drop the group from state
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	if groupState := state.GetGroup(groupName); groupState != nil {
+		hooks, _ := stopHooks(procMgr, state, groupName, groupState, os.Stdout)
+		removeEphemeralData(os.Stdout, groupName, hooks.group)
+		stopSidecars(os.Stdout, state, groupName, groupState)
+	}
+	state.DeleteGroup(groupName)
+	if err := state.Save(config.GetStatePath()); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	fmt.Printf("\n✓ Group %q stopped\n", groupName)
+	return nil
+}
+
+// stopUpGroup runs the stop hooks and terminates the process group of every
+// service of a group run by up
+func stopUpGroup(procMgr *process.Manager, groupName string) {
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		fmt.Printf("⚠ Failed to load state: %v\n", err)
+		killGroup(procMgr, groupName, syscall.SIGTERM)
+		return
+	}
+
+	groupState := state.GetGroup(groupName)
+	if groupState == nil {
+		return
+	}
+
+	stopGroupServices(procMgr, state, groupName, groupState, func(pid int) error {
+		return procMgr.SignalGroup(pid, syscall.SIGTERM)
+	}, os.Stdout)
+}
+
+// stopLaunched terminates the process groups of the services up launched
+// before the group failed to start, waiting for them to exit and killing
+// them if they don't in time
+func stopLaunched(procMgr *process.Manager, pids []int, exited <-chan serviceExit, output *prefixOutput) {
+	for _, pid := range pids {
+		procMgr.SignalGroup(pid, syscall.SIGTERM)
+	}
+
+	timeout := time.After(10 * time.Second)
+	for range pids {
+		select {
+		case exit := <-exited:
+			output.flush(exit.name)
+		case <-timeout:
+			for _, pid := range pids {
+				procMgr.SignalGroup(pid, syscall.SIGKILL)
+			}
+			return
+		}
+	}
+}
+
+// killGroup signals the process group of every service of a group
+func killGroup(procMgr *process.Manager, groupName string, sig syscall.Signal) {
+	state, err := config.LoadState(config.GetStatePath())
+	if err != nil {
+		return
+	}
+	groupState := state.GetGroup(groupName)
+	if groupState == nil {
+		return
+	}
+	for _, pid := range []int{groupState.BackendPID, groupState.FrontendPID} {
+		procMgr.SignalGroup(pid, sig)
+	}
+}
+
+// describeExit describes how a service process exited
+func describeExit(err error) string {
+	if err == nil {
+		return "exited with code 0"
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return fmt.Sprintf("stopped by signal (%s)", status.Signal())
+		}
+		return fmt.Sprintf("exited with code %d", exitErr.ExitCode())
+	}
+	return fmt.Sprintf("exited: %v", err)
+}
+
+// ANSI colors cycled through for service prefixes
+var prefixColors = []string{"36", "33", "32", "35", "34", "31"}
+
+// prefixOutput multiplexes the output of several services onto one writer,
+// prefixing every line with the service name. Lines are written whole so
+// output from different services never interleaves mid-line.
+type prefixOutput struct {
+	mu      sync.Mutex
+	out     io.Writer
+	color   bool
+	width   int
+	writers map[string]*prefixWriter
+}
+
+func newPrefixOutput(out *os.File) *prefixOutput {
+	color := false
+	if info, err := out.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "" {
+		color = true
+	}
+
+	return &prefixOutput{
+		out:     out,
+		color:   color,
+		width:   8,
+		writers: make(map[string]*prefixWriter),
+	}
+}
+
+// writer returns the writer for a service, creating it on first use
+func (p *prefixOutput) writer(name string) io.Writer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if w, ok := p.writers[name]; ok {
+		return w
+	}
+	if len(name) > p.width {
+		p.width = len(name)
+	}
+	w := &prefixWriter{parent: p, name: name, color: prefixColors[len(p.writers)%len(prefixColors)]}
+	p.writers[name] = w
+	return w
+}
+
+// prefix returns the formatted prefix for a service
+func (p *prefixOutput) prefix(name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.prefixLocked(name)
+}
+
+func (p *prefixOutput) prefixLocked(name string) string {
+	label := fmt.Sprintf("%-*s |", p.width, name)
+	if w, ok := p.writers[name]; ok && p.color {
+		return "\x1b[" + w.color + "m" + label + "\x1b[0m"
+	}
+	return label
+}
+
+// flush writes any partial last line buffered for a service
+func (p *prefixOutput) flush(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if w, ok := p.writers[name]; ok && len(w.buf) > 0 {
+		fmt.Fprintf(p.out, "%s %s\n", p.prefixLocked(name), w.buf)
+		w.buf = nil
+	}
+}
+
+// prefixWriter buffers a service's output until complete lines are available
+type prefixWriter struct {
+	parent *prefixOutput
+	name   string
+	color  string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(data []byte) (int, error) {
+	p := w.parent
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		fmt.Fprintf(p.out, "%s %s\n", p.prefixLocked(w.name), w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(data), nil
+}
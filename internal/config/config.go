@@ -4,55 +4,398 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the grappler configuration file
 type Config struct {
-	Version string            `yaml:"version"`
-	Groups  map[string]*Group `yaml:"groups"`
-	Proxy   *ProxyConfig      `yaml:"proxy,omitempty"`
+	Version   string            `yaml:"version"`
+	Templates *Templates        `yaml:"templates,omitempty"`
+	Groups    map[string]*Group `yaml:"groups"`
+	Proxy     *ProxyConfig      `yaml:"proxy,omitempty"`
+	Ports     *PortsConfig      `yaml:"ports,omitempty"`
+
+	// file and positions record where each value was read from, for
+	// validation
+	file      string
+	positions map[string]position
 }
 
 // Group represents a worktree group (backend + frontend pair)
 type Group struct {
-	Name     string   `yaml:"name"`
-	Backend  *Service `yaml:"backend"`
-	Frontend *Service `yaml:"frontend,omitempty"`
+	Name string `yaml:"name,omitempty"`
+	// Extends names the group template this group is layered over
+	Extends string `yaml:"extends,omitempty"`
+	// Description says what the group is for, shown by status
+	Description string            `yaml:"description,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Backend     *Service          `yaml:"backend,omitempty"`
+	Frontend    *Service          `yaml:"frontend,omitempty"`
+	Hooks       *Hooks            `yaml:"hooks,omitempty"`
+	EnvFiles    []EnvFile         `yaml:"env_files,omitempty"`
+	Database    *Database         `yaml:"database,omitempty"`
+	// Sidecars are supporting processes started before the services
+	Sidecars map[string]*Sidecar `yaml:"sidecars,omitempty"`
+
+	// repoErr is why a repo config in the group's worktrees couldn't be
+	// applied; the group can't be used until it is fixed
+	repoErr error
+}
+
+// RepoConfigErr returns why the repo configs of the group's worktrees
+// couldn't be applied, or nil if they were
+func (g *Group) RepoConfigErr() error {
+	return g.repoErr
+}
+
+// Database configures a database of the group's own, created on start on
+// a local server (or as a file) and dropped when the group is removed
+type Database struct {
+	// Provider is postgres, mysql or sqlite
+	Provider string `yaml:"provider"`
+	// URL is the admin connection URL of the local server
+	URL string `yaml:"url,omitempty"`
+	// Name is the database name, grappler_${group} by default, with the
+	// profile after grappler_ in a profile other than the default
+	Name string `yaml:"name,omitempty"`
+	// Template is a database to clone, or for sqlite a file to copy
+	Template string `yaml:"template,omitempty"`
+	// Schema isolates the group with a schema in the URL's database
+	// instead of a database (postgres only)
+	Schema bool `yaml:"schema,omitempty"`
+	// Env is the env var services get the URL in, DATABASE_URL by default
+	Env string `yaml:"env,omitempty"`
+	// Keep leaves the database in place when the group is removed
+	Keep bool `yaml:"keep,omitempty"`
+}
+
+// Sidecar is a process the group's services depend on, such as a Redis or
+// Postgres server or a mock of an external API. Sidecars start before the
+// group's hooks and services, on ports from their own range.
+type Sidecar struct {
+	Command Command `yaml:"command"`
+	// Directory is where the sidecar runs, its data directory by default
+	Directory string            `yaml:"directory,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+	// Scope is group (the default) to run one per group, or shared to run
+	// one for every group that defines a sidecar of the same name
+	Scope string `yaml:"scope,omitempty"`
+	// Export maps env vars set for the group's services and hooks to values
+	// built from the sidecar's ${port}, e.g. REDIS_URL: redis://localhost:${port}
+	Export map[string]string `yaml:"export,omitempty"`
+	// Timeout is how long to wait for the sidecar to accept connections on
+	// its port, 30s by default
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// Sidecar scopes
+const (
+	SidecarGroup  = "group"
+	SidecarShared = "shared"
+)
+
+// Shared reports whether one instance of the sidecar serves every group
+// that defines it
+func (s *Sidecar) Shared() bool {
+	return s.Scope == SidecarShared
+}
+
+// SidecarNames returns the names of a group's sidecars, sorted, which is
+// the order they start in
+func (g *Group) SidecarNames() []string {
+	names := make([]string, 0, len(g.Sidecars))
+	for name := range g.Sidecars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NamedService pairs a service with its name within a group
+type NamedService struct {
+	Name    string
+	Service *Service
+}
+
+// ServiceList returns the services of a group in start order
+func (g *Group) ServiceList() []NamedService {
+	var services []NamedService
+	if g.Backend != nil {
+		services = append(services, NamedService{Name: "backend", Service: g.Backend})
+	}
+	if g.Frontend != nil {
+		services = append(services, NamedService{Name: "frontend", Service: g.Frontend})
+	}
+	return services
+}
+
+// Directory returns the directory group-level commands run in: the backend
+// worktree, or the frontend worktree for frontend-only groups
+func (g *Group) Directory() string {
+	if g.Backend != nil {
+		return g.Backend.Directory
+	}
+	if g.Frontend != nil {
+		return g.Frontend.Directory
+	}
+	return ""
+}
+
+// Service returns the service with the given name, or nil
+func (g *Group) Service(name string) *Service {
+	for _, svc := range g.ServiceList() {
+		if svc.Name == name {
+			return svc.Service
+		}
+	}
+	return nil
+}
+
+// SetService replaces the service with the given name
+func (g *Group) SetService(name string, service *Service) {
+	switch name {
+	case "backend":
+		g.Backend = service
+	case "frontend":
+		g.Frontend = service
+	}
 }
 
 // Service represents a single service (backend or frontend)
 type Service struct {
-	Directory string            `yaml:"directory"`
+	// Extends names the service template this service is layered over
+	Extends   string            `yaml:"extends,omitempty"`
+	Directory string            `yaml:"directory,omitempty"`
 	Branch    string            `yaml:"branch,omitempty"`
-	Command   string            `yaml:"command"`
+	Command   Command           `yaml:"command,omitempty"`
+	Shell     string            `yaml:"shell,omitempty"`
 	Env       map[string]string `yaml:"env,omitempty"`
+	EnvFiles  []EnvFile         `yaml:"env_files,omitempty"`
+	// EnvFromServices maps env vars to a value of another service in the
+	// group, as "<service>.<field>" (e.g. VITE_API_URL: backend.url)
+	EnvFromServices map[string]string `yaml:"env_from_services,omitempty"`
+	Hooks           *Hooks            `yaml:"hooks,omitempty"`
+	Health          *HealthCheck      `yaml:"health,omitempty"`
+	Data            *Data             `yaml:"data,omitempty"`
+	// RepoConfig is the path of the repo config the service was layered
+	// over, if any
+	RepoConfig string `yaml:"-"`
+}
+
+// Data configures how a service's data directory is filled when it is
+// created. The directory itself always exists while the service runs.
+type Data struct {
+	// Seed lists files and directories copied into the data directory,
+	// relative to the service directory
+	Seed []string `yaml:"seed,omitempty"`
+	// SeedFrom names a group whose data for the same service is copied
+	// instead, when it has any
+	SeedFrom string `yaml:"seed_from,omitempty"`
+	// Ephemeral removes the data directory when the group stops, so every
+	// run starts from the seed
+	Ephemeral bool `yaml:"ephemeral,omitempty"`
+}
+
+// SeedPath returns the path of a data seed of a service running in dir:
+// relative seeds are relative to it
+func SeedPath(dir, seed string) string {
+	if filepath.IsAbs(seed) {
+		return seed
+	}
+	return filepath.Join(dir, seed)
+}
+
+// Command is a service command, written either as a command line string
+// or as a list of arguments that is executed as-is without parsing
+type Command struct {
+	Line string
+	Args []string
+}
+
+// NewCommand returns a command for a command line string
+func NewCommand(line string) Command {
+	return Command{Line: line}
+}
+
+// UnmarshalYAML accepts a string or a list of strings
+func (c *Command) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		c.Line = value.Value
+		c.Args = nil
+		return nil
+	case yaml.SequenceNode:
+		c.Line = ""
+		return value.Decode(&c.Args)
+	}
+	return fmt.Errorf("line %d: command must be a string or a list of strings", value.Line)
+}
+
+// MarshalYAML writes the list form when arguments are set
+func (c Command) MarshalYAML() (interface{}, error) {
+	if len(c.Args) > 0 {
+		return c.Args, nil
+	}
+	return c.Line, nil
+}
+
+// IsZero reports whether the command is empty
+func (c Command) IsZero() bool {
+	return c.Line == "" && len(c.Args) == 0
+}
+
+// String returns the command for display
+func (c Command) String() string {
+	if len(c.Args) > 0 {
+		return fmt.Sprintf("%q", c.Args)
+	}
+	return c.Line
+}
+
+// Hooks holds commands run at points in a group or service lifecycle.
+// Setup hooks run once per worktree; the others run on every start/stop.
+type Hooks struct {
+	Setup     []string `yaml:"setup,omitempty"`
+	PreStart  []string `yaml:"pre_start,omitempty"`
+	PostStart []string `yaml:"post_start,omitempty"`
+	PreStop   []string `yaml:"pre_stop,omitempty"`
+	PostStop  []string `yaml:"post_stop,omitempty"`
+}
+
+// Hook stage names
+const (
+	HookSetup     = "setup"
+	HookPreStart  = "pre_start"
+	HookPostStart = "post_start"
+	HookPreStop   = "pre_stop"
+	HookPostStop  = "post_stop"
+)
+
+// Commands returns the hook commands for a stage
+func (h *Hooks) Commands(stage string) []string {
+	if h == nil {
+		return nil
+	}
+
+	switch stage {
+	case HookSetup:
+		return h.Setup
+	case HookPreStart:
+		return h.PreStart
+	case HookPostStart:
+		return h.PostStart
+	case HookPreStop:
+		return h.PreStop
+	case HookPostStop:
+		return h.PostStop
+	}
+	return nil
 }
 
 // ProxyConfig represents proxy configuration
 type ProxyConfig struct {
-	Enabled             bool `yaml:"enabled"`
+	Enabled              bool `yaml:"enabled"`
 	UseExistingConductor bool `yaml:"use_existing_conductor"`
 }
 
-// Load reads the config file from the specified path
+// PortsConfig sets the ranges service and sidecar ports are allocated from.
+// Unset ranges use the defaults.
+type PortsConfig struct {
+	Backend  PortRange `yaml:"backend,omitempty"`
+	Frontend PortRange `yaml:"frontend,omitempty"`
+	Sidecars PortRange `yaml:"sidecars,omitempty"`
+}
+
+// PortRange is an inclusive range of ports, written as "8000-8999"
+type PortRange struct {
+	Start int
+	End   int
+}
+
+// UnmarshalYAML parses a "start-end" range
+func (r *PortRange) UnmarshalYAML(value *yaml.Node) error {
+	start, end, ok := strings.Cut(value.Value, "-")
+	if value.Kind == yaml.ScalarNode && ok {
+		var errStart, errEnd error
+		r.Start, errStart = strconv.Atoi(strings.TrimSpace(start))
+		r.End, errEnd = strconv.Atoi(strings.TrimSpace(end))
+		if errStart == nil && errEnd == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("line %d: port range must look like 8000-8999", value.Line)
+}
+
+// MarshalYAML writes the range as "start-end"
+func (r PortRange) MarshalYAML() (interface{}, error) {
+	return fmt.Sprintf("%d-%d", r.Start, r.End), nil
+}
+
+// IsZero reports whether the range is unset
+func (r PortRange) IsZero() bool {
+	return r.Start == 0 && r.End == 0
+}
+
+// String returns the range as "start-end"
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// Load reads the config file from the specified path, applies the
+// templates groups and services extend, and layers each service over the
+// repo config in its worktree
 func Load(path string) (*Config, error) {
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.resolve(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// resolve turns the config as written into the one groups run with
+func (c *Config) resolve() error {
+	if err := c.applyTemplates(); err != nil {
+		return err
+	}
+	c.applyRepoConfigs()
+	return nil
+}
+
+// LoadFile reads the config file alone, without templates or repo configs
+// applied, for commands that edit and save it. A config written by an older
+// grappler is migrated in memory; one from a newer grappler is refused.
+func LoadFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	plan, err := migrateConfig(path, data)
+	if err != nil {
+		return nil, err
+	}
+
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	cfg.file = path
+	if cfg.positions, err = decodeStrict(path, plan.data, &cfg); err != nil {
+		return nil, err
 	}
 
 	return &cfg, nil
 }
 
-// Save writes the config to the specified path
+// Save writes the config to the specified path, stamped with the current
+// ConfigVersion
 func (c *Config) Save(path string) error {
+	c.Version = strconv.Itoa(ConfigVersion)
 	data, err := yaml.Marshal(c)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -70,11 +413,11 @@ func (c *Config) Save(path string) error {
 	return nil
 }
 
-// GetConfigPath returns the path to the grappler config file
+// GetConfigPath returns the path to the config file: $GRAPPLER_CONFIG, or
+// the config of the active profile
 func GetConfigPath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join(".grappler", "config.yaml")
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path
 	}
-	return filepath.Join(home, ".grappler", "config.yaml")
+	return filepath.Join(HomeDir(), "config.yaml")
 }
@@ -0,0 +1,519 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a config file parsed as a YAML node tree. Edits made through
+// it keep the file's comments, key order and indentation, where Save
+// re-marshals the whole config.
+type Document struct {
+	path   string
+	root   yaml.Node
+	indent int
+}
+
+// LoadDocument reads a config file for editing. A missing file gives an
+// empty document, created on Save.
+func LoadDocument(path string) (*Document, error) {
+	doc := &Document{path: path, indent: 4}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err == nil {
+		plan, err := migrateConfig(path, data)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(plan.data, &doc.root); err != nil {
+			return nil, &ValidationError{Problems: yamlProblems(path, err)}
+		}
+		doc.indent = detectIndent(data)
+	}
+
+	if documentRoot(&doc.root) == nil {
+		doc.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if documentRoot(&doc.root).Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping at the top level", path)
+	}
+	return doc, nil
+}
+
+// Config decodes the edited document as Load would, with templates and the
+// repo configs of its worktrees applied
+func (d *Document) Config() (*Config, error) {
+	cfg, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.resolve(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Save writes the document back to its file, stamped with the current
+// ConfigVersion. Edits that leave the config undecodable are refused.
+func (d *Document) Save() error {
+	setMappingValue(documentRoot(&d.root), "version", strconv.Itoa(ConfigVersion))
+	if _, err := d.decode(); err != nil {
+		return err
+	}
+
+	data, err := d.encode()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp, d.path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// HasGroup reports whether the document defines a group
+func (d *Document) HasGroup(name string) bool {
+	return mappingValue(d.groups(false), name) != nil
+}
+
+// GroupYAML returns a group as written in the file, comments included
+func (d *Document) GroupYAML(name string) ([]byte, error) {
+	if groups := d.groups(false); groups != nil {
+		for i := 0; i+1 < len(groups.Content); i += 2 {
+			if groups.Content[i].Value != name {
+				continue
+			}
+			entry := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: groups.Content[i : i+2]}
+			return encodeNode(entry, d.indent)
+		}
+	}
+	return nil, fmt.Errorf("group %q not found in config", name)
+}
+
+// SetGroup adds a group, or replaces the definition of an existing one.
+// Comments on the group's key are kept; unset services are left out.
+func (d *Document) SetGroup(name string, group *Group) error {
+	var node yaml.Node
+	if err := node.Encode(group); err != nil {
+		return fmt.Errorf("failed to encode group %q: %w", name, err)
+	}
+	for i := 0; i+1 < len(node.Content); {
+		if node.Content[i+1].Tag == "!!null" {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			continue
+		}
+		i += 2
+	}
+
+	groups := d.groups(true)
+	if existing := mappingValue(groups, name); existing != nil {
+		node.HeadComment, node.LineComment, node.FootComment = existing.HeadComment, existing.LineComment, existing.FootComment
+		*existing = node
+		return nil
+	}
+	groups.Content = append(groups.Content, scalarNode(name, "!!str"), &node)
+	return nil
+}
+
+// RemoveGroup deletes a group
+func (d *Document) RemoveGroup(name string) error {
+	if !d.HasGroup(name) {
+		return fmt.Errorf("group %q not found in config", name)
+	}
+	deleteMappingKey(d.groups(false), name)
+	return nil
+}
+
+// RemoveService deletes a service of a group
+func (d *Document) RemoveService(groupName, serviceName string) error {
+	if _, err := d.service(groupName, serviceName, false); err != nil {
+		return err
+	}
+	deleteMappingKey(mappingValue(d.groups(false), groupName), serviceName)
+	return nil
+}
+
+// SetDescription sets the description of a group, or removes it if empty
+func (d *Document) SetDescription(groupName, description string) error {
+	group, err := d.group(groupName)
+	if err != nil {
+		return err
+	}
+	if description == "" {
+		deleteMappingKey(group, "description")
+		return nil
+	}
+	setMappingScalar(group, "description", description)
+	return nil
+}
+
+// SetLabel sets a label of a group, creating its labels as needed
+func (d *Document) SetLabel(groupName, key, value string) error {
+	group, err := d.group(groupName)
+	if err != nil {
+		return err
+	}
+	labels := mappingValue(group, "labels")
+	if labels == nil || labels.Kind != yaml.MappingNode {
+		labels = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingNode(group, "labels", labels)
+	}
+	setMappingScalar(labels, key, value)
+	return nil
+}
+
+// UnsetLabel removes a label of a group, dropping its labels if none are
+// left
+func (d *Document) UnsetLabel(groupName, key string) error {
+	group, err := d.group(groupName)
+	if err != nil {
+		return err
+	}
+	labels := mappingValue(group, "labels")
+	if mappingValue(labels, key) == nil {
+		return fmt.Errorf("group %q has no label %q", groupName, key)
+	}
+	deleteMappingKey(labels, key)
+	if len(labels.Content) == 0 {
+		deleteMappingKey(group, "labels")
+	}
+	return nil
+}
+
+// RenameGroup renames a group in place, along with its name field when it
+// still matches the old name and the data.seed_from of services seeding
+// from it
+func (d *Document) RenameGroup(oldName, newName string) error {
+	groups := d.groups(false)
+	if !d.HasGroup(oldName) {
+		return fmt.Errorf("group %q not found in config", oldName)
+	}
+	if d.HasGroup(newName) {
+		return fmt.Errorf("group %q already exists", newName)
+	}
+
+	for i := 0; i+1 < len(groups.Content); i += 2 {
+		if groups.Content[i].Value != oldName {
+			continue
+		}
+		groups.Content[i].Value = newName
+		if field := mappingValue(groups.Content[i+1], "name"); field != nil && field.Value == oldName {
+			field.Value = newName
+		}
+	}
+
+	for i := 1; i < len(groups.Content); i += 2 {
+		for _, role := range []string{"backend", "frontend"} {
+			data := mappingValue(mappingValue(groups.Content[i], role), "data")
+			if field := mappingValue(data, "seed_from"); field != nil && field.Value == oldName {
+				field.Value = newName
+			}
+		}
+	}
+	return nil
+}
+
+// SetServiceValue sets a field of a service from a dotted key, such as
+// command, health.path or env.FOO, creating the service and any
+// intermediate mappings as needed
+func (d *Document) SetServiceValue(groupName, serviceName, key, value string) error {
+	path, t, err := servicePath(key)
+	if err != nil {
+		return err
+	}
+
+	tag := "!!str"
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected true or false, got %q", key, value)
+		}
+		value, tag = strconv.FormatBool(b), "!!bool"
+	case reflect.Int:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s: expected a number, got %q", key, value)
+		}
+		tag = "!!int"
+	}
+
+	node, err := d.service(groupName, serviceName, true)
+	if err != nil {
+		return err
+	}
+	for _, part := range path[:len(path)-1] {
+		child := mappingValue(node, part)
+		if child == nil || child.Kind != yaml.MappingNode {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingNode(node, part, child)
+		}
+		node = child
+	}
+
+	last := path[len(path)-1]
+	if existing := mappingValue(node, last); existing != nil {
+		// Keep the comments around the value, but not the list it may have been
+		existing.Kind, existing.Tag, existing.Value, existing.Style, existing.Content = yaml.ScalarNode, tag, value, 0, nil
+		return nil
+	}
+	setMappingNode(node, last, scalarNode(value, tag))
+	return nil
+}
+
+// UnsetServiceValue removes a field of a service from a dotted key,
+// dropping mappings it leaves empty
+func (d *Document) UnsetServiceValue(groupName, serviceName, key string) error {
+	path, _, err := servicePath(key)
+	if err != nil {
+		return err
+	}
+
+	node, err := d.service(groupName, serviceName, false)
+	if err != nil {
+		return err
+	}
+
+	parents := []*yaml.Node{node}
+	for _, part := range path[:len(path)-1] {
+		if node = mappingValue(node, part); node == nil {
+			return fmt.Errorf("%s is not set on %s of group %q", key, serviceName, groupName)
+		}
+		parents = append(parents, node)
+	}
+	if mappingValue(node, path[len(path)-1]) == nil {
+		return fmt.Errorf("%s is not set on %s of group %q", key, serviceName, groupName)
+	}
+	deleteMappingKey(node, path[len(path)-1])
+
+	for i := len(parents) - 1; i > 0 && len(parents[i].Content) == 0; i-- {
+		deleteMappingKey(parents[i-1], path[i-1])
+	}
+	return nil
+}
+
+// groups returns the groups mapping, creating it if asked to
+func (d *Document) groups(create bool) *yaml.Node {
+	root := documentRoot(&d.root)
+	groups := mappingValue(root, "groups")
+	if (groups == nil || groups.Kind != yaml.MappingNode) && create {
+		groups = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingNode(root, "groups", groups)
+	}
+	return groups
+}
+
+// group returns the mapping of a group
+func (d *Document) group(name string) (*yaml.Node, error) {
+	group := mappingValue(d.groups(false), name)
+	if group == nil || group.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("group %q not found in config", name)
+	}
+	return group, nil
+}
+
+// service returns the mapping of a service, creating it if asked to
+func (d *Document) service(groupName, serviceName string, create bool) (*yaml.Node, error) {
+	if serviceName != "backend" && serviceName != "frontend" {
+		return nil, fmt.Errorf("unknown service %q: groups have a backend and a frontend", serviceName)
+	}
+
+	group, err := d.group(groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	service := mappingValue(group, serviceName)
+	if service == nil || service.Kind != yaml.MappingNode {
+		if !create {
+			return nil, fmt.Errorf("group %q has no service %q", groupName, serviceName)
+		}
+		service = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingNode(group, serviceName, service)
+	}
+	return service, nil
+}
+
+func (d *Document) encode() ([]byte, error) {
+	return encodeNode(&d.root, d.indent)
+}
+
+func (d *Document) decode() (*Config, error) {
+	data, err := d.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Config{file: d.path}
+	if cfg.positions, err = decodeStrict(d.path, data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+var commandType = reflect.TypeOf(Command{})
+
+// servicePath splits a dotted service key into the keys it sets, checking
+// them against the Service type. Everything after a map field, such as
+// env, is a single key. It returns the type of the value being set.
+func servicePath(key string) ([]string, reflect.Type, error) {
+	parts := strings.Split(key, ".")
+	t := reflect.TypeOf(Service{})
+
+	for i, part := range parts {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Map {
+			return append(parts[:i:i], strings.Join(parts[i:], ".")), t.Elem(), nil
+		}
+		if t.Kind() != reflect.Struct || t == commandType {
+			return nil, nil, fmt.Errorf("invalid key %q: %s has no fields", key, strings.Join(parts[:i], "."))
+		}
+
+		fields := yamlFields(t)
+		field, ok := fields[part]
+		if !ok || part == "" {
+			return nil, nil, fmt.Errorf("invalid key %q: unknown field %q%s", key, part, suggest(part, fields))
+		}
+		t = field
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == commandType, t.Kind() == reflect.String, t.Kind() == reflect.Bool, t.Kind() == reflect.Int:
+		return parts, t, nil
+	case t.Kind() == reflect.Map:
+		return nil, nil, fmt.Errorf("invalid key %q: set one entry, as in %s.NAME=value", key, key)
+	case t.Kind() == reflect.Struct:
+		return nil, nil, fmt.Errorf("invalid key %q: set one of its fields, as in %s.%s=value", key, key, firstField(t))
+	}
+	return nil, nil, fmt.Errorf("invalid key %q: lists can't be set from the command line; edit the config file", key)
+}
+
+// firstField returns the alphabetically first YAML field of a struct
+func firstField(t reflect.Type) string {
+	first := ""
+	for name := range yamlFields(t) {
+		if first == "" || name < first {
+			first = name
+		}
+	}
+	return first
+}
+
+// encodeNode encodes a node tree with the given indentation
+func encodeNode(node *yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(node); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func scalarNode(value, tag string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+// documentRoot returns the top-level mapping of a parsed document
+func documentRoot(root *yaml.Node) *yaml.Node {
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		return root.Content[0]
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets a string value in a mapping node, adding the key
+// first if it is missing
+func setMappingValue(node *yaml.Node, key, value string) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Kind, existing.Tag, existing.Value, existing.Style = yaml.ScalarNode, "!!str", value, yaml.DoubleQuotedStyle
+		return
+	}
+	node.Content = append([]*yaml.Node{
+		scalarNode(key, "!!str"),
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: yaml.DoubleQuotedStyle},
+	}, node.Content...)
+}
+
+// setMappingNode sets the value of key in a mapping node, adding the key
+// last if it is missing
+func setMappingNode(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, scalarNode(key, "!!str"), value)
+}
+
+// setMappingScalar sets a string value in a mapping node, keeping the
+// style and comments of an existing value and adding the key last if it is
+// missing
+func setMappingScalar(node *yaml.Node, key, value string) {
+	if existing := mappingValue(node, key); existing != nil && existing.Kind == yaml.ScalarNode {
+		existing.Tag, existing.Value = "!!str", value
+		return
+	}
+	setMappingNode(node, key, scalarNode(value, "!!str"))
+}
+
+// deleteMappingKey removes a key and its value from a mapping node
+func deleteMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// detectIndent returns the indentation a YAML file uses, so rewritten files
+// keep their look. Files without nested mappings get yaml.Marshal's 4.
+func detectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return indent
+		}
+	}
+	return 4
+}
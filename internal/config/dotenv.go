@@ -0,0 +1,210 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile is a dotenv file loaded into a service's environment. Relative
+// paths are resolved against the service directory. Missing files are an
+// error unless Optional is set.
+type EnvFile struct {
+	Path     string `yaml:"path"`
+	Optional bool   `yaml:"optional,omitempty"`
+}
+
+// UnmarshalYAML accepts a plain path or a mapping with path and optional
+func (f *EnvFile) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		f.Path = value.Value
+		f.Optional = false
+		return nil
+	}
+
+	type plain EnvFile
+	return value.Decode((*plain)(f))
+}
+
+// MarshalYAML writes required files as a plain path
+func (f EnvFile) MarshalYAML() (interface{}, error) {
+	if !f.Optional {
+		return f.Path, nil
+	}
+
+	type plain EnvFile
+	return plain(f), nil
+}
+
+// DotenvEntry is a single assignment read from a dotenv file
+type DotenvEntry struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// ReadDotenv parses a dotenv file. Lines have the form KEY=value, optionally
+// prefixed with "export". Single-quoted values are literal; double-quoted
+// values support \n, \t, \" and \\ escapes and may span lines. Unquoted and
+// double-quoted values expand $VAR and ${VAR} from earlier entries in the
+// file, then from lookup. Unquoted values end at " #".
+func ReadDotenv(path string, lookup func(string) (string, bool)) ([]DotenvEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var entries []DotenvEntry
+	values := make(map[string]string)
+	expandLookup := func(key string) string {
+		if value, ok := values[key]; ok {
+			return value
+		}
+		if lookup != nil {
+			value, _ := lookup(key)
+			return value
+		}
+		return ""
+	}
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !isEnvKey(key) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", path, lineNo)
+		}
+		raw = strings.TrimSpace(raw)
+
+		var value string
+		switch {
+		case strings.HasPrefix(raw, "'"):
+			end := strings.Index(raw[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated single quote", path, lineNo)
+			}
+			value = raw[1 : end+1]
+		case strings.HasPrefix(raw, `"`):
+			// Double-quoted values may continue onto following lines
+			body := raw[1:]
+			for !hasClosingQuote(body) {
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("%s:%d: unterminated double quote", path, lineNo)
+				}
+				body += "\n" + lines[i]
+			}
+			value = expandDotenv(body[:closingQuote(body)], expandLookup, true)
+		default:
+			if idx := strings.Index(raw, " #"); idx >= 0 {
+				raw = strings.TrimSpace(raw[:idx])
+			}
+			value = expandDotenv(raw, expandLookup, false)
+		}
+
+		values[key] = value
+		entries = append(entries, DotenvEntry{Key: key, Value: value, Line: lineNo})
+	}
+
+	return entries, nil
+}
+
+// closingQuote returns the index of the first unescaped double quote, or -1
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func hasClosingQuote(s string) bool {
+	return closingQuote(s) >= 0
+}
+
+// expandDotenv expands $VAR and ${VAR} references, and with escapes also
+// the \n, \t, \", \\ and \$ escape sequences of double-quoted values
+func expandDotenv(s string, lookup func(string) string, escapes bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if escapes && c == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i+1])
+			default:
+				b.WriteByte(c)
+				continue
+			}
+			i++
+			continue
+		}
+		if c != '$' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+
+		if s[i+1] == '{' {
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteString(lookup(s[i+2 : i+end]))
+			i += end
+			continue
+		}
+
+		j := i + 1
+		for j < len(s) && (s[j] == '_' || (s[j] >= 'a' && s[j] <= 'z') || (s[j] >= 'A' && s[j] <= 'Z') || (j > i+1 && s[j] >= '0' && s[j] <= '9')) {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString(lookup(s[i+1 : j]))
+		i = j - 1
+	}
+	return b.String()
+}
+
+func isEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') || (i > 0 && c == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeDotenv(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadDotenv(t *testing.T) {
+	host := map[string]string{"HOST_VAR": "from-host"}
+	lookup := func(key string) (string, bool) {
+		value, ok := host[key]
+		return value, ok
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{name: "plain", content: "A=1\nB = two \n", want: map[string]string{"A": "1", "B": "two"}},
+		{name: "blank lines and comments", content: "\n# comment\n  # indented\nA=1\n", want: map[string]string{"A": "1"}},
+		{name: "export prefix", content: "export A=1\nexport   B=2\n", want: map[string]string{"A": "1", "B": "2"}},
+		{name: "empty value", content: "A=\nB=''\nC=\"\"\n", want: map[string]string{"A": "", "B": "", "C": ""}},
+		{name: "equals in value", content: "URL=postgres://h/db?a=b\n", want: map[string]string{"URL": "postgres://h/db?a=b"}},
+		{name: "inline comment", content: "A=1 # note\nB=x#y\nC='v # kept'\nD=\"w # kept\"\n", want: map[string]string{"A": "1", "B": "x#y", "C": "v # kept", "D": "w # kept"}},
+		{name: "single quotes are literal", content: `A='$HOST_VAR \n "x"'` + "\n", want: map[string]string{"A": `$HOST_VAR \n "x"`}},
+		{name: "double-quote escapes", content: `A="a\nb\tc \"q\" \\ \$HOST_VAR \x"` + "\n", want: map[string]string{"A": "a\nb\tc \"q\" \\ $HOST_VAR \\x"}},
+		{name: "multiline double quotes", content: "KEY=\"-----BEGIN-----\nline two\n-----END-----\"\nNEXT=1\n", want: map[string]string{"KEY": "-----BEGIN-----\nline two\n-----END-----", "NEXT": "1"}},
+		{name: "escaped quote before line end", content: "A=\"say \\\"\nhi\"\n", want: map[string]string{"A": "say \"\nhi"}},
+		{
+			name:    "expansion from earlier entries and the host",
+			content: "BASE=/srv\nDIR=$BASE/app\nLOG=\"${DIR}/log\"\nH=$HOST_VAR\nM=[$MISSING]\n",
+			want:    map[string]string{"BASE": "/srv", "DIR": "/srv/app", "LOG": "/srv/app/log", "H": "from-host", "M": "[]"},
+		},
+		{name: "earlier entries win over the host", content: "HOST_VAR=mine\nA=$HOST_VAR\n", want: map[string]string{"HOST_VAR": "mine", "A": "mine"}},
+		{name: "lone dollar", content: "A=cost$\nB=$1\nC=${unterminated\n", want: map[string]string{"A": "cost$", "B": "$1", "C": "${unterminated"}},
+		{name: "dotted keys", content: "spring.profile=dev\n", want: map[string]string{"spring.profile": "dev"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ReadDotenv(writeDotenv(t, tt.content), lookup)
+			if err != nil {
+				t.Fatalf("ReadDotenv() error = %v", err)
+			}
+			got := make(map[string]string)
+			for _, entry := range entries {
+				got[entry.Key] = entry.Value
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadDotenv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDotenvLines(t *testing.T) {
+	entries, err := ReadDotenv(writeDotenv(t, "# header\nA=1\nB=\"x\ny\"\nC=3\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, entry := range entries {
+		lines = append(lines, entry.Line)
+	}
+	if want := []int{2, 3, 5}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("entry lines = %v, want %v", lines, want)
+	}
+}
+
+func TestReadDotenvErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "no equals", content: "A=1\nJUST_A_WORD\n", want: ":2: expected KEY=value"},
+		{name: "invalid key", content: "1A=1\n", want: ":1: expected KEY=value"},
+		{name: "empty key", content: "=1\n", want: ":1: expected KEY=value"},
+		{name: "unterminated single quote", content: "A='abc\n", want: ":1: unterminated single quote"},
+		{name: "unterminated double quote", content: "A=1\nB=\"abc\nmore\n", want: ":2: unterminated double quote"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadDotenv(writeDotenv(t, tt.content), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ReadDotenv() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadDotenv(filepath.Join(t.TempDir(), "missing.env"), nil); !os.IsNotExist(err) {
+		t.Errorf("ReadDotenv() of a missing file error = %v, want one satisfying os.IsNotExist", err)
+	}
+}
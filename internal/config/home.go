@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Environment variables that choose where grappler keeps its files. The
+// --home, --profile and --config flags set them, so hooks and services that
+// run grappler again use the same files.
+const (
+	HomeEnv    = "GRAPPLER_HOME"
+	ProfileEnv = "GRAPPLER_PROFILE"
+	ConfigEnv  = "GRAPPLER_CONFIG"
+)
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// SetPaths overrides the grappler home, profile and config file for this
+// process and the processes it starts. Empty values keep the current ones.
+func SetPaths(home, profile, configPath string) error {
+	if home != "" {
+		abs, err := filepath.Abs(home)
+		if err != nil {
+			return fmt.Errorf("invalid home %q: %w", home, err)
+		}
+		os.Setenv(HomeEnv, abs)
+	}
+	if profile != "" {
+		os.Setenv(ProfileEnv, profile)
+	}
+	if configPath != "" {
+		abs, err := filepath.Abs(configPath)
+		if err != nil {
+			return fmt.Errorf("invalid config path %q: %w", configPath, err)
+		}
+		os.Setenv(ConfigEnv, abs)
+	}
+
+	if p := Profile(); p != "" && !profileName.MatchString(p) {
+		return fmt.Errorf("invalid profile %q: use letters, digits, '.', '_' and '-'", p)
+	}
+	return nil
+}
+
+// BaseDir returns the grappler home shared by all profiles: $GRAPPLER_HOME,
+// or ~/.grappler
+func BaseDir() string {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		return dir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".grappler"
+	}
+	return filepath.Join(home, ".grappler")
+}
+
+// Profile returns the name of the active profile, or "" for the default one
+func Profile() string {
+	return os.Getenv(ProfileEnv)
+}
+
+// HomeDir returns the directory holding the config, state, logs and runtime
+// files of the active profile
+func HomeDir() string {
+	if profile := Profile(); profile != "" {
+		return filepath.Join(BaseDir(), "profiles", profile)
+	}
+	return BaseDir()
+}
+
+// OtherProfilePorts returns the ports reserved in the state of every other
+// profile of the grappler home, so no two profiles hand out the same port
+func OtherProfilePorts() map[int]bool {
+	paths := []string{filepath.Join(BaseDir(), "state.json")}
+	profiles, _ := filepath.Glob(filepath.Join(BaseDir(), "profiles", "*", "state.json"))
+	paths = append(paths, profiles...)
+
+	current := GetStatePath()
+	reserved := make(map[int]bool)
+	for _, path := range paths {
+		if path == current {
+			continue
+		}
+
+		// Unreadable states reserve nothing; their own profile reports them
+		state, err := LoadState(path)
+		if err != nil {
+			continue
+		}
+		for _, groupState := range state.GroupStates() {
+			for _, port := range groupState.Ports() {
+				reserved[port] = true
+			}
+		}
+	}
+	return reserved
+}
@@ -0,0 +1,634 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Vars holds the values available to ${...} references in a group's
+// service definitions:
+//
+//	${group}                    the group name
+//	${service}                  the current service name
+//	${port}, ${url}             the current service's allocated port and URL
+//	${branch}, ${worktree}      the current service's branch and directory
+//	${data_dir}                 the current service's data directory
+//	${services.<name>.<field>}  port, url, directory, branch or data_dir of any service in the group
+//	${database.<field>}         name or url of the group's database
+//	${sidecars.<name>.<field>}  port, address or data_dir of a sidecar of the group
+//	${env.NAME}                 a host environment variable
+//
+// In sidecar definitions, ${service}, ${port} and ${data_dir} refer to the
+// sidecar itself.
+//
+// Any reference can take a fallback with ${ref:-default}, and $${ escapes a
+// literal ${.
+type Vars struct {
+	Group    string
+	Services map[string]*ServiceVars
+	// Database is the group's database, if it has one
+	Database *DatabaseVars
+	// Sidecars holds the group's sidecars, keyed by name
+	Sidecars map[string]*SidecarVars
+	// Env holds the group's per-run env overrides. They are not available to
+	// interpolation, but are layered into every service's environment.
+	Env map[string]string
+}
+
+// ServiceVars are the values of a single service available to interpolation
+type ServiceVars struct {
+	Port      int
+	Directory string
+	Branch    string
+	DataDir   string
+}
+
+// SidecarVars are the values of a sidecar available to interpolation
+type SidecarVars struct {
+	Port    int
+	DataDir string
+	Shared  bool
+}
+
+// DatabaseVars describe a group's database
+type DatabaseVars struct {
+	// Provider names the kind of server: postgres, mysql or sqlite
+	Provider string
+	// AdminURL is the interpolated admin connection URL of the server
+	AdminURL string
+	Schema   bool
+	// Dir is the directory file-based providers keep the database in
+	Dir      string
+	Name     string
+	URL      string
+	Template string
+	// Env is the env var the URL is injected as
+	Env string
+}
+
+// DatabaseResolver checks a group's database against its provider and
+// returns the URL services connect to it with
+type DatabaseResolver func(db *DatabaseVars) (string, error)
+
+// databaseResolver is set by the layer that provisions databases; config
+// itself knows nothing of database servers
+var databaseResolver DatabaseResolver
+
+// SetDatabaseResolver sets how the URLs of groups' databases are resolved.
+// Until it is set, databases resolve without a URL.
+func SetDatabaseResolver(resolver DatabaseResolver) {
+	databaseResolver = resolver
+}
+
+// NewVars builds the interpolation context for a group. ports maps service
+// and sidecar names to allocated ports and may omit those that have none.
+// Directories are resolved first, so they may not reference other directories.
+func NewVars(groupName string, group *Group, ports map[string]int) (*Vars, error) {
+	v := &Vars{
+		Group:    groupName,
+		Services: make(map[string]*ServiceVars),
+		Sidecars: make(map[string]*SidecarVars),
+	}
+
+	for _, name := range group.SidecarNames() {
+		sidecar := group.Sidecars[name]
+		if sidecar == nil {
+			return nil, fmt.Errorf("sidecars.%s: empty sidecar", name)
+		}
+		if group.Service(name) != nil || name == "backend" || name == "frontend" {
+			return nil, fmt.Errorf("sidecars.%s: %q is a service name", name, name)
+		}
+		sv := &SidecarVars{
+			Port:    ports[name],
+			DataDir: DataDir(groupName, name),
+			Shared:  sidecar.Shared(),
+		}
+		if sv.Shared {
+			sv.DataDir = SharedDataDir(name)
+		}
+		v.Sidecars[name] = sv
+	}
+
+	for _, svc := range group.ServiceList() {
+		v.Services[svc.Name] = &ServiceVars{
+			Port:    ports[svc.Name],
+			Branch:  svc.Service.Branch,
+			DataDir: DataDir(groupName, svc.Name),
+		}
+	}
+
+	for _, svc := range group.ServiceList() {
+		dir, err := v.interpolate(svc.Service.Directory, svc.Name, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s.directory: %w", svc.Name, err)
+		}
+		v.Services[svc.Name].Directory = dir
+	}
+
+	if group.Database != nil {
+		db, err := v.resolveDatabase(group.Database)
+		if err != nil {
+			return nil, fmt.Errorf("database: %w", err)
+		}
+		v.Database = db
+	}
+
+	return v, nil
+}
+
+// resolveDatabase interpolates a database block and resolves its URL.
+// The name is made a valid identifier. The default one includes the profile,
+// as profiles often share a database server.
+func (v *Vars) resolveDatabase(db *Database) (*DatabaseVars, error) {
+	name := db.Name
+	if name == "" {
+		name = "grappler_${group}"
+		if profile := Profile(); profile != "" {
+			name = "grappler_" + profile + "_${group}"
+		}
+	}
+	var adminURL, template string
+	fields := []struct {
+		key string
+		src string
+		dst *string
+	}{
+		{"name", name, &name},
+		{"url", db.URL, &adminURL},
+		{"template", db.Template, &template},
+	}
+	for _, field := range fields {
+		value, err := v.interpolate(field.src, "", false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.key, err)
+		}
+		*field.dst = value
+	}
+
+	if db.Provider == "sqlite" && template != "" && !filepath.IsAbs(template) {
+		return nil, fmt.Errorf("template: sqlite templates are paths to a file and must be absolute, got %q", template)
+	}
+	if db.Schema && template != "" {
+		return nil, fmt.Errorf("template: schemas can't be cloned from a template")
+	}
+
+	resolved := &DatabaseVars{
+		Provider: db.Provider,
+		AdminURL: adminURL,
+		Schema:   db.Schema,
+		Dir:      filepath.Join(GetDataDir(), v.Group),
+		Name:     databaseIdentifier(name),
+		Template: template,
+		Env:      db.Env,
+	}
+	if resolved.Env == "" {
+		resolved.Env = "DATABASE_URL"
+	}
+	if databaseResolver != nil {
+		url, err := databaseResolver(resolved)
+		if err != nil {
+			return nil, err
+		}
+		resolved.URL = url
+	}
+	return resolved, nil
+}
+
+var identifierInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// databaseIdentifier turns a name such as a group name into a database
+// name: lower case, with runs of anything other than letters, digits and _
+// replaced by _, and at most 63 characters
+func databaseIdentifier(name string) string {
+	id := identifierInvalid.ReplaceAllString(strings.ToLower(name), "_")
+	if len(id) > 63 {
+		id = id[:63]
+	}
+	return id
+}
+
+// ResolveSidecar returns a copy of a sidecar with every reference in its
+// command, directory, env and exports interpolated. The directory defaults
+// to the sidecar's data directory.
+func (v *Vars) ResolveSidecar(name string, sidecar *Sidecar) (*Sidecar, error) {
+	resolved := *sidecar
+
+	var err error
+	if resolved.Command.Line, err = v.interpolate(sidecar.Command.Line, name, true); err != nil {
+		return nil, fmt.Errorf("sidecars.%s.command: %w", name, err)
+	}
+	if len(sidecar.Command.Args) > 0 {
+		resolved.Command.Args = make([]string, len(sidecar.Command.Args))
+		for i, arg := range sidecar.Command.Args {
+			if resolved.Command.Args[i], err = v.interpolate(arg, name, false); err != nil {
+				return nil, fmt.Errorf("sidecars.%s.command[%d]: %w", name, i, err)
+			}
+		}
+	}
+
+	if resolved.Directory, err = v.interpolate(sidecar.Directory, name, false); err != nil {
+		return nil, fmt.Errorf("sidecars.%s.directory: %w", name, err)
+	}
+	if resolved.Directory == "" {
+		resolved.Directory = v.Sidecars[name].DataDir
+	}
+
+	maps := []struct {
+		key string
+		src map[string]string
+		dst *map[string]string
+	}{
+		{"env", sidecar.Env, &resolved.Env},
+		{"export", sidecar.Export, &resolved.Export},
+	}
+	for _, m := range maps {
+		if m.src == nil {
+			continue
+		}
+		*m.dst = make(map[string]string, len(m.src))
+		for key, value := range m.src {
+			if (*m.dst)[key], err = v.interpolate(value, name, false); err != nil {
+				return nil, fmt.Errorf("sidecars.%s.%s.%s: %w", name, m.key, key, err)
+			}
+		}
+	}
+
+	return &resolved, nil
+}
+
+// Directory returns the resolved directory of a service
+func (v *Vars) Directory(serviceName string) string {
+	if svc := v.Services[serviceName]; svc != nil {
+		return svc.Directory
+	}
+	return ""
+}
+
+// ResolveGroup returns a copy of the group with every reference in its
+// services' directories, commands, env and hooks interpolated
+func (v *Vars) ResolveGroup(group *Group) (*Group, error) {
+	resolved := *group
+
+	hooks, err := v.resolveHooks(group.Hooks, "")
+	if err != nil {
+		return nil, fmt.Errorf("hooks: %w", err)
+	}
+	resolved.Hooks = hooks
+
+	envFiles, err := v.resolveEnvFiles(group.EnvFiles, "")
+	if err != nil {
+		return nil, fmt.Errorf("env_files: %w", err)
+	}
+	resolved.EnvFiles = envFiles
+
+	if group.Sidecars != nil {
+		resolved.Sidecars = make(map[string]*Sidecar, len(group.Sidecars))
+		for _, name := range group.SidecarNames() {
+			sidecar, err := v.ResolveSidecar(name, group.Sidecars[name])
+			if err != nil {
+				return nil, err
+			}
+			resolved.Sidecars[name] = sidecar
+		}
+	}
+
+	for _, svc := range group.ServiceList() {
+		service, err := v.ResolveService(svc.Name, svc.Service)
+		if err != nil {
+			return nil, err
+		}
+		resolved.SetService(svc.Name, service)
+	}
+
+	return &resolved, nil
+}
+
+// WithDirectories returns a copy of the group with only its service
+// directories resolved, for callers that don't need ports
+func (v *Vars) WithDirectories(group *Group) *Group {
+	resolved := *group
+	for _, svc := range group.ServiceList() {
+		service := *svc.Service
+		service.Directory = v.Directory(svc.Name)
+		resolved.SetService(svc.Name, &service)
+	}
+	return &resolved
+}
+
+// ResolveService returns a copy of a service with every reference in its
+// directory, command, env and hooks interpolated. Plain ${NAME} references
+// in commands are left for the command's own variable expansion.
+func (v *Vars) ResolveService(serviceName string, service *Service) (*Service, error) {
+	resolved := *service
+	resolved.Directory = v.Directory(serviceName)
+
+	var err error
+	if resolved.Command.Line, err = v.interpolate(service.Command.Line, serviceName, true); err != nil {
+		return nil, fmt.Errorf("%s.command: %w", serviceName, err)
+	}
+	if len(service.Command.Args) > 0 {
+		resolved.Command.Args = make([]string, len(service.Command.Args))
+		for i, arg := range service.Command.Args {
+			if resolved.Command.Args[i], err = v.interpolate(arg, serviceName, false); err != nil {
+				return nil, fmt.Errorf("%s.command[%d]: %w", serviceName, i, err)
+			}
+		}
+	}
+
+	if service.Env != nil {
+		resolved.Env = make(map[string]string, len(service.Env))
+		for key, value := range service.Env {
+			if resolved.Env[key], err = v.interpolate(value, serviceName, false); err != nil {
+				return nil, fmt.Errorf("%s.env.%s: %w", serviceName, key, err)
+			}
+		}
+	}
+
+	if resolved.EnvFiles, err = v.resolveEnvFiles(service.EnvFiles, serviceName); err != nil {
+		return nil, fmt.Errorf("%s.env_files: %w", serviceName, err)
+	}
+
+	if resolved.Hooks, err = v.resolveHooks(service.Hooks, serviceName); err != nil {
+		return nil, fmt.Errorf("%s.hooks: %w", serviceName, err)
+	}
+
+	if service.Data != nil && len(service.Data.Seed) > 0 {
+		data := *service.Data
+		data.Seed = make([]string, len(service.Data.Seed))
+		for i, path := range service.Data.Seed {
+			if data.Seed[i], err = v.interpolate(path, serviceName, false); err != nil {
+				return nil, fmt.Errorf("%s.data.seed[%d]: %w", serviceName, i, err)
+			}
+		}
+		resolved.Data = &data
+	}
+
+	return &resolved, nil
+}
+
+func (v *Vars) resolveEnvFiles(files []EnvFile, serviceName string) ([]EnvFile, error) {
+	if files == nil {
+		return nil, nil
+	}
+
+	resolved := make([]EnvFile, len(files))
+	for i, file := range files {
+		path, err := v.interpolate(file.Path, serviceName, false)
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+		resolved[i] = EnvFile{Path: path, Optional: file.Optional}
+	}
+	return resolved, nil
+}
+
+func (v *Vars) resolveHooks(hooks *Hooks, serviceName string) (*Hooks, error) {
+	if hooks == nil {
+		return nil, nil
+	}
+
+	resolved := &Hooks{}
+	stages := []struct {
+		name string
+		src  []string
+		dst  *[]string
+	}{
+		{HookSetup, hooks.Setup, &resolved.Setup},
+		{HookPreStart, hooks.PreStart, &resolved.PreStart},
+		{HookPostStart, hooks.PostStart, &resolved.PostStart},
+		{HookPreStop, hooks.PreStop, &resolved.PreStop},
+		{HookPostStop, hooks.PostStop, &resolved.PostStop},
+	}
+	for _, stage := range stages {
+		for i, command := range stage.src {
+			value, err := v.interpolate(command, serviceName, true)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", stage.name, i, err)
+			}
+			*stage.dst = append(*stage.dst, value)
+		}
+	}
+
+	return resolved, nil
+}
+
+// interpolate replaces ${...} references in s. With keepPlain, references
+// to plain variable names are kept for shell-style expansion later.
+func (v *Vars) interpolate(s, serviceName string, keepPlain bool) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		// $${ escapes a literal ${
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", s)
+		}
+		end += i
+
+		b.WriteString(s[:i])
+		expr := s[i+2 : end]
+		s = s[end+1:]
+
+		ref, fallback, hasDefault := strings.Cut(expr, ":-")
+		if keepPlain && !v.isReference(ref) {
+			b.WriteString("${" + expr + "}")
+			continue
+		}
+
+		value, err := v.lookup(ref, serviceName)
+		if err != nil {
+			if !hasDefault {
+				return "", fmt.Errorf("unresolved reference ${%s}: %w", expr, err)
+			}
+			value = fallback
+		}
+		b.WriteString(value)
+	}
+}
+
+// isReference reports whether ref names an interpolation value rather than
+// a plain variable
+func (v *Vars) isReference(ref string) bool {
+	switch ref {
+	case "group", "service", "port", "url", "branch", "worktree", "data_dir":
+		return true
+	}
+	return strings.Contains(ref, ".")
+}
+
+func (v *Vars) lookup(ref, serviceName string) (string, error) {
+	switch ref {
+	case "group":
+		return v.Group, nil
+	case "service", "port", "url", "branch", "worktree", "data_dir":
+		if serviceName == "" {
+			return "", fmt.Errorf("only available in service definitions")
+		}
+		if ref == "service" {
+			return serviceName, nil
+		}
+		if _, ok := v.Sidecars[serviceName]; ok {
+			if ref != "port" && ref != "data_dir" {
+				return "", fmt.Errorf("not available in sidecar definitions")
+			}
+			return v.sidecarField(serviceName, ref)
+		}
+		field := map[string]string{"port": "port", "url": "url", "branch": "branch", "worktree": "directory", "data_dir": "data_dir"}[ref]
+		return v.serviceField(serviceName, field)
+	}
+
+	namespace, rest, ok := strings.Cut(ref, ".")
+	if !ok {
+		return "", fmt.Errorf("unknown variable %q", ref)
+	}
+
+	switch namespace {
+	case "env":
+		value, ok := os.LookupEnv(rest)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", rest)
+		}
+		return value, nil
+	case "services":
+		name, field, ok := strings.Cut(rest, ".")
+		if !ok {
+			return "", fmt.Errorf("expected services.<name>.<field>")
+		}
+		return v.serviceField(name, field)
+	case "sidecars":
+		name, field, ok := strings.Cut(rest, ".")
+		if !ok {
+			return "", fmt.Errorf("expected sidecars.<name>.<field>")
+		}
+		return v.sidecarField(name, field)
+	case "database":
+		if v.Database == nil {
+			return "", fmt.Errorf("group %q has no database", v.Group)
+		}
+		switch rest {
+		case "name":
+			return v.Database.Name, nil
+		case "url":
+			return v.Database.URL, nil
+		}
+		return "", fmt.Errorf("unknown field %q (expected name or url)", rest)
+	}
+
+	return "", fmt.Errorf("unknown namespace %q", namespace)
+}
+
+// ServiceValue returns a field (port, url, directory, branch or data_dir) of
+// a service
+func (v *Vars) ServiceValue(serviceName, field string) (string, error) {
+	return v.serviceField(serviceName, field)
+}
+
+// DiscoveryEnv returns the discovery variables injected into every service
+// of the group: GRAPPLER_GROUP plus GRAPPLER_<SERVICE>_PORT and
+// GRAPPLER_<SERVICE>_URL for each service with an allocated port, and
+// GRAPPLER_<SIDECAR>_PORT for each sidecar
+func (v *Vars) DiscoveryEnv() map[string]string {
+	env := map[string]string{
+		"GRAPPLER_GROUP": v.Group,
+	}
+
+	for name, svc := range v.Services {
+		if svc.Port == 0 {
+			continue
+		}
+		prefix := "GRAPPLER_" + EnvName(name)
+		env[prefix+"_PORT"] = fmt.Sprintf("%d", svc.Port)
+		env[prefix+"_URL"] = fmt.Sprintf("http://localhost:%d", svc.Port)
+	}
+	for name, sidecar := range v.Sidecars {
+		if sidecar.Port > 0 {
+			env["GRAPPLER_"+EnvName(name)+"_PORT"] = fmt.Sprintf("%d", sidecar.Port)
+		}
+	}
+
+	return env
+}
+
+// EnvName converts a name to an environment variable name component:
+// upper case, with anything other than letters and digits replaced by _
+func EnvName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func (v *Vars) serviceField(serviceName, field string) (string, error) {
+	svc, ok := v.Services[serviceName]
+	if !ok {
+		return "", fmt.Errorf("group %q has no service %q", v.Group, serviceName)
+	}
+
+	switch field {
+	case "port", "url":
+		if svc.Port == 0 {
+			return "", fmt.Errorf("service %q has no allocated port", serviceName)
+		}
+		if field == "url" {
+			return fmt.Sprintf("http://localhost:%d", svc.Port), nil
+		}
+		return fmt.Sprintf("%d", svc.Port), nil
+	case "directory":
+		if svc.Directory == "" {
+			return "", fmt.Errorf("directory of service %q is not available here", serviceName)
+		}
+		return svc.Directory, nil
+	case "branch":
+		return svc.Branch, nil
+	case "data_dir":
+		return svc.DataDir, nil
+	}
+
+	return "", fmt.Errorf("unknown field %q (expected port, url, directory, branch or data_dir)", field)
+}
+
+func (v *Vars) sidecarField(name, field string) (string, error) {
+	sidecar, ok := v.Sidecars[name]
+	if !ok {
+		return "", fmt.Errorf("group %q has no sidecar %q", v.Group, name)
+	}
+
+	switch field {
+	case "port", "address":
+		if sidecar.Port == 0 {
+			return "", fmt.Errorf("sidecar %q has no allocated port", name)
+		}
+		if field == "address" {
+			return fmt.Sprintf("localhost:%d", sidecar.Port), nil
+		}
+		return fmt.Sprintf("%d", sidecar.Port), nil
+	case "data_dir":
+		return sidecar.DataDir, nil
+	}
+
+	return "", fmt.Errorf("unknown field %q (expected port, address or data_dir)", field)
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Versions of the files this grappler reads and writes. Files written by an
+// older grappler are migrated; files from a newer one are refused.
+const (
+	ConfigVersion = 2
+	StateVersion  = 1
+)
+
+// configMigrations upgrade the config document, in order. Each produces
+// version index+2; files without a version are version 1.
+var configMigrations = []migration[*yaml.Node]{
+	{
+		description: "drop the commands init used to hardcode where the worktree's .grappler.yaml sets one",
+		apply:       dropDefaultCommands,
+	},
+}
+
+// stateMigrations upgrade the state document, in order. Each produces
+// version index+1; files without a version are version 0.
+var stateMigrations = []migration[map[string]interface{}]{
+	{
+		description: "record the state version",
+		apply:       func(map[string]interface{}) error { return nil },
+	},
+}
+
+type migration[T any] struct {
+	description string
+	apply       func(doc T) error
+}
+
+// MigrationPlan describes the migrations pending for a file
+type MigrationPlan struct {
+	Path  string
+	From  int
+	To    int
+	Steps []string
+	data  []byte
+}
+
+// Backup returns the path the original file is copied to
+func (p *MigrationPlan) Backup() string {
+	return fmt.Sprintf("%s.v%d.bak", p.Path, p.From)
+}
+
+// Apply copies the original file to its backup and replaces it with the
+// migrated one
+func (p *MigrationPlan) Apply() error {
+	original, err := os.ReadFile(p.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", p.Path, err)
+	}
+	if err := os.WriteFile(p.Backup(), original, 0644); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", p.Path, err)
+	}
+
+	tmp := p.Path + ".tmp"
+	if err := os.WriteFile(tmp, p.data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", p.Path, err)
+	}
+	if err := os.Rename(tmp, p.Path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", p.Path, err)
+	}
+	return nil
+}
+
+// PlanConfigMigration returns the migrations pending for a config file, or
+// nil if it is missing or up to date
+func PlanConfigMigration(path string) (*MigrationPlan, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	plan, err := migrateConfig(path, data)
+	if err != nil || plan.From == plan.To {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanStateMigration returns the migrations pending for a state file, or
+// nil if it is missing or up to date
+func PlanStateMigration(path string) (*MigrationPlan, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	plan, err := migrateState(path, data)
+	if err != nil || plan.From == plan.To {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// migrateConfig applies the pending config migrations to data. Edits are
+// made on the YAML node tree, so comments and key order survive.
+func migrateConfig(path string, data []byte) (*MigrationPlan, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, &ValidationError{Problems: yamlProblems(path, err)}
+	}
+	doc := documentRoot(&root)
+
+	version := 1
+	if node := mappingValue(doc, "version"); node != nil {
+		v, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d:%d: version: expected a number, got %q", path, node.Line, node.Column, node.Value)
+		}
+		version = v
+	}
+	plan := &MigrationPlan{Path: path, From: version, To: version, data: data}
+
+	if version > ConfigVersion {
+		return nil, newerFileError(path, version, ConfigVersion)
+	}
+	if version < 1 {
+		return nil, invalidVersionError(path, version, 1)
+	}
+	if version == ConfigVersion || doc == nil {
+		return plan, nil
+	}
+
+	for _, m := range configMigrations[version-1:] {
+		if err := m.apply(doc); err != nil {
+			return nil, fmt.Errorf("failed to migrate %s to version %d: %w", path, plan.To+1, err)
+		}
+		plan.To++
+		plan.Steps = append(plan.Steps, fmt.Sprintf("v%d: %s", plan.To, m.description))
+	}
+	setMappingValue(doc, "version", strconv.Itoa(plan.To))
+
+	migrated, err := encodeNode(&root, detectIndent(data))
+	if err != nil {
+		return nil, err
+	}
+	plan.data = migrated
+	return plan, nil
+}
+
+// migrateState applies the pending state migrations to data
+func migrateState(path string, data []byte) (*MigrationPlan, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	version := 0
+	if v, ok := doc["version"].(float64); ok {
+		version = int(v)
+	}
+	plan := &MigrationPlan{Path: path, From: version, To: version, data: data}
+
+	if version > StateVersion {
+		return nil, newerFileError(path, version, StateVersion)
+	}
+	if version < 0 {
+		return nil, invalidVersionError(path, version, 0)
+	}
+	if version == StateVersion {
+		return plan, nil
+	}
+
+	for _, m := range stateMigrations[version:] {
+		if err := m.apply(doc); err != nil {
+			return nil, fmt.Errorf("failed to migrate %s to version %d: %w", path, plan.To+1, err)
+		}
+		plan.To++
+		plan.Steps = append(plan.Steps, fmt.Sprintf("v%d: %s", plan.To, m.description))
+	}
+	doc["version"] = plan.To
+
+	migrated, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write migrated state: %w", err)
+	}
+	plan.data = migrated
+	return plan, nil
+}
+
+func newerFileError(path string, version, supported int) error {
+	return fmt.Errorf("%s is version %d, written by a newer grappler (this one reads up to version %d); upgrade grappler", path, version, supported)
+}
+
+func invalidVersionError(path string, version, oldest int) error {
+	return fmt.Errorf("%s is version %d, which no grappler writes (versions start at %d); fix or remove the version", path, version, oldest)
+}
+
+// Commands PairWorktrees wrote into every group before services were
+// defined by each repo's .grappler.yaml
+var legacyDefaultCommands = map[string]string{
+	"backend":  "go run cmd/api-server/main.go",
+	"frontend": "pnpm conductor:customer",
+}
+
+// dropDefaultCommands removes service commands init used to hardcode, which
+// would otherwise override the command of the worktree's .grappler.yaml.
+// Services whose worktree doesn't set a command keep theirs.
+func dropDefaultCommands(doc *yaml.Node) error {
+	groups := mappingValue(doc, "groups")
+	if groups == nil || groups.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 1; i < len(groups.Content); i += 2 {
+		for role, legacy := range legacyDefaultCommands {
+			service := mappingValue(groups.Content[i], role)
+			command := mappingValue(service, "command")
+			dir := mappingValue(service, "directory")
+			if command == nil || command.Value != legacy || dir == nil || strings.Contains(dir.Value, "${") {
+				continue
+			}
+
+			repo, err := ReadRepoConfig(dir.Value)
+			if err != nil || repo == nil || repo.ServiceFor(role).Command.IsZero() {
+				continue
+			}
+			deleteMappingKey(service, "command")
+		}
+	}
+	return nil
+}
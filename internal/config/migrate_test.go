@@ -0,0 +1,81 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMigrateConfigVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		wantErr string
+		wantTo  int
+	}{
+		{name: "unversioned", version: "", wantTo: ConfigVersion},
+		{name: "first", version: "1", wantTo: ConfigVersion},
+		{name: "current", version: strconv.Itoa(ConfigVersion), wantTo: ConfigVersion},
+		{name: "zero", version: "0", wantErr: "which no grappler writes"},
+		{name: "negative", version: "-3", wantErr: "which no grappler writes"},
+		{name: "newer", version: strconv.Itoa(ConfigVersion + 1), wantErr: "written by a newer grappler"},
+		{name: "not a number", version: "two", wantErr: "expected a number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := "groups: {}\n"
+			if tt.version != "" {
+				data = "version: \"" + tt.version + "\"\n" + data
+			}
+
+			plan, err := migrateConfig("config.yaml", []byte(data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("migrateConfig() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("migrateConfig() error = %v", err)
+			}
+			if plan.To != tt.wantTo {
+				t.Errorf("migrateConfig() migrated to version %d, want %d", plan.To, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestMigrateStateVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+		wantTo  int
+	}{
+		{name: "unversioned", data: `{"groups": {}}`, wantTo: StateVersion},
+		{name: "zero", data: `{"version": 0}`, wantTo: StateVersion},
+		{name: "current", data: `{"version": ` + strconv.Itoa(StateVersion) + `}`, wantTo: StateVersion},
+		{name: "negative", data: `{"version": -1}`, wantErr: "which no grappler writes"},
+		{name: "newer", data: `{"version": ` + strconv.Itoa(StateVersion+1) + `}`, wantErr: "written by a newer grappler"},
+		{name: "invalid JSON", data: `{`, wantErr: "failed to parse state file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := migrateState("state.json", []byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("migrateState() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("migrateState() error = %v", err)
+			}
+			if plan.To != tt.wantTo {
+				t.Errorf("migrateState() migrated to version %d, want %d", plan.To, tt.wantTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RepoConfigFile is the file a repository checks in to describe its service
+const RepoConfigFile = ".grappler.yaml"
+
+// RepoConfig is a repository's own description of the service it runs,
+// read from the root of each worktree. Service fields at the top level
+// apply whichever role the repo plays; a repo serving both roles, such as a
+// monorepo, can describe each under backend and frontend instead. The
+// directory and branch always come from the grappler config.
+type RepoConfig struct {
+	Service  `yaml:",inline"`
+	Backend  *Service `yaml:"backend,omitempty"`
+	Frontend *Service `yaml:"frontend,omitempty"`
+}
+
+// HealthCheck configures how start waits for a service to come up. By
+// default it waits up to 30s for the service's port to answer HTTP
+// requests with a status below 500.
+type HealthCheck struct {
+	Path     string `yaml:"path,omitempty"`
+	Timeout  string `yaml:"timeout,omitempty"`
+	Disabled bool   `yaml:"disabled,omitempty"`
+}
+
+// ReadRepoConfig reads the repo config in a worktree, returning nil if it
+// has none
+func ReadRepoConfig(dir string) (*RepoConfig, error) {
+	path := filepath.Join(dir, RepoConfigFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var repo RepoConfig
+	positions, err := decodeStrict(path, data, &repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates are defined in the grappler config, out of the repo's reach
+	var problems []Problem
+	for prefix, svc := range map[string]*Service{"": &repo.Service, "backend": repo.Backend, "frontend": repo.Frontend} {
+		if svc == nil || svc.Extends == "" {
+			continue
+		}
+		at := joinPath(prefix, "extends")
+		pos := positions[at]
+		problems = append(problems, Problem{File: path, Line: pos.line, Column: pos.column, Path: at, Message: "extends is only supported in the grappler config"})
+	}
+	if len(problems) > 0 {
+		sortProblems(problems)
+		return nil, &ValidationError{Problems: problems}
+	}
+	return &repo, nil
+}
+
+// ServiceFor returns the repo's definition of a service playing the given
+// role
+func (r *RepoConfig) ServiceFor(role string) *Service {
+	switch {
+	case role == "backend" && r.Backend != nil:
+		return r.Backend
+	case role == "frontend" && r.Frontend != nil:
+		return r.Frontend
+	}
+	return &r.Service
+}
+
+// applyRepoConfigs layers each group's services over the repo config in
+// their worktree, so the grappler config only holds overrides. A repo config
+// that can't be read is recorded on the groups using it rather than failing
+// the whole config; Validate reports it.
+func (c *Config) applyRepoConfigs() {
+	for groupName, group := range c.Groups {
+		if group == nil {
+			continue
+		}
+
+		// Directories may use interpolation; groups whose directories
+		// can't be resolved fail later with a better error
+		vars, err := NewVars(groupName, group, nil)
+		if err != nil {
+			continue
+		}
+
+		var problems []Problem
+		for _, svc := range group.ServiceList() {
+			repo, err := ReadRepoConfig(vars.Directory(svc.Name))
+			var invalid *ValidationError
+			if errors.As(err, &invalid) {
+				problems = append(problems, invalid.Problems...)
+				continue
+			}
+			if err != nil {
+				if group.repoErr == nil {
+					group.repoErr = fmt.Errorf("%s: %w", svc.Name, err)
+				}
+				continue
+			}
+			if repo == nil {
+				continue
+			}
+
+			merged := MergeService(repo.ServiceFor(svc.Name), svc.Service)
+			merged.RepoConfig = filepath.Join(vars.Directory(svc.Name), RepoConfigFile)
+			group.SetService(svc.Name, merged)
+		}
+
+		// Both services may run from the same worktree
+		if problems = uniqueProblems(problems); len(problems) > 0 && group.repoErr == nil {
+			sortProblems(problems)
+			group.repoErr = &ValidationError{Problems: problems}
+		}
+	}
+}
+
+// MergeService layers override on top of base. Set scalar fields and hook
+// stages in override win, env maps are merged key by key, and env files of
+// both are loaded, base first. The directory and branch are override's.
+func MergeService(base, override *Service) *Service {
+	merged := *override
+	if merged.Command.IsZero() {
+		merged.Command = base.Command
+	}
+	if merged.Shell == "" {
+		merged.Shell = base.Shell
+	}
+	if merged.Health == nil {
+		merged.Health = base.Health
+	}
+	if merged.Data == nil {
+		merged.Data = base.Data
+	}
+
+	merged.Env = mergeMaps(base.Env, override.Env)
+	merged.EnvFromServices = mergeMaps(base.EnvFromServices, override.EnvFromServices)
+	if len(base.EnvFiles) > 0 {
+		merged.EnvFiles = append(append([]EnvFile(nil), base.EnvFiles...), override.EnvFiles...)
+	}
+
+	merged.Hooks = mergeHooks(base.Hooks, override.Hooks)
+
+	return &merged
+}
+
+// mergeHooks returns base's hooks with the stages override sets replacing
+// base's
+func mergeHooks(base, override *Hooks) *Hooks {
+	if base == nil {
+		return override
+	}
+
+	hooks := *base
+	if override != nil {
+		for _, stage := range []struct{ dst, src *[]string }{
+			{&hooks.Setup, &override.Setup},
+			{&hooks.PreStart, &override.PreStart},
+			{&hooks.PostStart, &override.PostStart},
+			{&hooks.PreStop, &override.PreStop},
+			{&hooks.PostStop, &override.PostStop},
+		} {
+			if *stage.src != nil {
+				*stage.dst = *stage.src
+			}
+		}
+	}
+	return &hooks
+}
+
+// mergeMaps returns base with override's entries on top, or nil if both
+// are empty
+func mergeMaps(base, override map[string]string) map[string]string {
+	if len(base) == 0 {
+		return override
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,19 @@
+package config
+
+import "embed"
+
+// schemas holds the JSON Schemas of the config file and repo config, for
+// editor completion and validation
+//
+//go:embed schema/*.schema.json
+var schemas embed.FS
+
+// Schema returns the JSON Schema of the config file, or of the repo config
+// when repo is set
+func Schema(repo bool) ([]byte, error) {
+	name := "schema/config.schema.json"
+	if repo {
+		name = "schema/repo.schema.json"
+	}
+	return schemas.ReadFile(name)
+}
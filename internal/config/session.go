@@ -0,0 +1,94 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session is a saved set of running groups that can be brought back together
+type Session struct {
+	Name    string                   `json:"name"`
+	SavedAt time.Time                `json:"saved_at"`
+	Groups  map[string]*SessionGroup `json:"groups"`
+}
+
+// SessionGroup records how a group was running when a session was saved
+type SessionGroup struct {
+	// Services lists the services that were running; others were stopped
+	Services []string          `json:"services"`
+	Ports    map[string]int    `json:"ports,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+}
+
+// LoadSession reads a session file from the specified path
+func LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session file: %w", err)
+	}
+
+	if session.Groups == nil {
+		session.Groups = make(map[string]*SessionGroup)
+	}
+
+	return &session, nil
+}
+
+// Save writes the session to the specified path
+func (s *Session) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+
+	return nil
+}
+
+// ListSessions returns the names of the saved sessions, sorted
+func ListSessions() ([]string, error) {
+	entries, err := os.ReadDir(GetSessionsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetSessionsDir returns the path to the sessions directory of the active
+// profile
+func GetSessionsDir() string {
+	return filepath.Join(HomeDir(), "sessions")
+}
+
+// GetSessionPath returns the path to the file of a named session
+func GetSessionPath(name string) string {
+	return filepath.Join(GetSessionsDir(), name+".json")
+}
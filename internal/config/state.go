@@ -5,32 +5,193 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 )
 
 // State represents the runtime state of grappler
 type State struct {
 	mu     sync.RWMutex
-	Groups map[string]*GroupState `json:"groups"`
+	saveMu sync.Mutex
+	// Version is the state format; see StateVersion
+	Version int                    `json:"version"`
+	Groups  map[string]*GroupState `json:"groups"`
+	// Setup records completed setup hooks, keyed by worktree directory
+	// (or "group:<name>" for group hooks), mapped to a hash of the commands
+	Setup map[string]string `json:"setup,omitempty"`
+	// BootID identifies the system boot the running groups were started in
+	BootID string `json:"boot_id,omitempty"`
+	// Resume holds the groups that were running when the system restarted,
+	// to be started again with 'grappler resume'
+	Resume map[string]*SessionGroup `json:"resume,omitempty"`
+	// Sidecars holds the running shared sidecars, keyed by name
+	Sidecars map[string]*SidecarState `json:"sidecars,omitempty"`
 }
 
 // GroupState represents the runtime state of a single group
 type GroupState struct {
-	BackendPort  int `json:"backend_port,omitempty"`
-	FrontendPort int `json:"frontend_port,omitempty"`
-	BackendPID   int `json:"backend_pid,omitempty"`
-	FrontendPID  int `json:"frontend_pid,omitempty"`
+	BackendPort  int  `json:"backend_port,omitempty"`
+	FrontendPort int  `json:"frontend_port,omitempty"`
+	BackendPID   int  `json:"backend_pid,omitempty"`
+	FrontendPID  int  `json:"frontend_pid,omitempty"`
 	Running      bool `json:"running"`
+	// Services holds per-service runtime details, keyed by service name
+	Services map[string]*ServiceState `json:"services,omitempty"`
+	// Env holds per-run env overrides given with 'grappler start --env'
+	Env map[string]string `json:"env,omitempty"`
+	// Systemd is set when the group runs under systemd user units installed
+	// with 'grappler systemd install'; its ports stay reserved for the units
+	Systemd bool `json:"systemd,omitempty"`
+	// Sidecars holds the sidecars the group uses, keyed by name. Shared
+	// ones are recorded with their port; their process is in State.Sidecars.
+	Sidecars map[string]*SidecarState `json:"sidecars,omitempty"`
+	// Session is set to tmux or zellij when the group's services run in the
+	// foreground of a multiplexer session, where grappler doesn't track them
+	Session string `json:"session,omitempty"`
+}
+
+// SidecarState represents a running sidecar
+type SidecarState struct {
+	Port   int      `json:"port"`
+	PID    int      `json:"pid,omitempty"`
+	Argv   []string `json:"argv,omitempty"`
+	Shared bool     `json:"shared,omitempty"`
+	// Groups lists the groups using a shared sidecar
+	Groups []string `json:"groups,omitempty"`
+}
+
+// ServiceState represents the runtime details of a single service
+type ServiceState struct {
+	Argv []string `json:"argv,omitempty"`
+	// Stopped is set when the service was stopped on its own, leaving the
+	// rest of the group running and its port reserved
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// Port returns the allocated port of a service
+func (g *GroupState) Port(name string) int {
+	if g == nil {
+		return 0
+	}
+	switch name {
+	case "backend":
+		return g.BackendPort
+	case "frontend":
+		return g.FrontendPort
+	}
+	if sidecar := g.Sidecars[name]; sidecar != nil {
+		return sidecar.Port
+	}
+	return 0
+}
+
+// ServiceNames returns the names of the services recorded for a group
+// (those with a port or a process), in start order
+func (g *GroupState) ServiceNames() []string {
+	var names []string
+	for _, name := range []string{"backend", "frontend"} {
+		if g.Port(name) > 0 || g.PID(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Ports returns the allocated ports keyed by service or sidecar name
+func (g *GroupState) Ports() map[string]int {
+	ports := make(map[string]int)
+	for _, name := range []string{"backend", "frontend"} {
+		if port := g.Port(name); port > 0 {
+			ports[name] = port
+		}
+	}
+	if g != nil {
+		for name, sidecar := range g.Sidecars {
+			ports[name] = sidecar.Port
+		}
+	}
+	return ports
+}
+
+// SetSidecar records a sidecar the group uses
+func (g *GroupState) SetSidecar(name string, sidecar *SidecarState) {
+	if g.Sidecars == nil {
+		g.Sidecars = make(map[string]*SidecarState)
+	}
+	g.Sidecars[name] = sidecar
+}
+
+// PID returns the process ID of a service, or 0 if it isn't running
+func (g *GroupState) PID(name string) int {
+	if g == nil {
+		return 0
+	}
+	switch name {
+	case "backend":
+		return g.BackendPID
+	case "frontend":
+		return g.FrontendPID
+	}
+	return 0
+}
+
+// SetPID records the process ID of a service
+func (g *GroupState) SetPID(name string, pid int) {
+	switch name {
+	case "backend":
+		g.BackendPID = pid
+	case "frontend":
+		g.FrontendPID = pid
+	}
+}
+
+// Service returns the runtime details for a service, or nil if none are recorded
+func (g *GroupState) Service(name string) *ServiceState {
+	if g == nil || g.Services == nil {
+		return nil
+	}
+	return g.Services[name]
+}
+
+// SetArgv records the argv a service was started with
+func (g *GroupState) SetArgv(name string, argv []string) {
+	if g.Services == nil {
+		g.Services = make(map[string]*ServiceState)
+	}
+	if g.Services[name] == nil {
+		g.Services[name] = &ServiceState{}
+	}
+	g.Services[name].Argv = argv
+}
+
+// SetStopped records whether a service was stopped on its own
+func (g *GroupState) SetStopped(name string, stopped bool) {
+	if g.Services == nil {
+		g.Services = make(map[string]*ServiceState)
+	}
+	if g.Services[name] == nil {
+		g.Services[name] = &ServiceState{}
+	}
+	g.Services[name].Stopped = stopped
+}
+
+// IsStopped reports whether a service was stopped on its own
+func (g *GroupState) IsStopped(name string) bool {
+	svc := g.Service(name)
+	return svc != nil && svc.Stopped
 }
 
 // NewState creates a new empty state
 func NewState() *State {
 	return &State{
-		Groups: make(map[string]*GroupState),
+		Version: StateVersion,
+		Groups:  make(map[string]*GroupState),
+		Setup:   make(map[string]string),
 	}
 }
 
-// LoadState reads the state file from the specified path
+// LoadState reads the state file from the specified path. State written by
+// an older grappler is migrated in memory; state from a newer one is refused.
 func LoadState(path string) (*State, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -40,24 +201,35 @@ func LoadState(path string) (*State, error) {
 		return nil, fmt.Errorf("failed to read state file: %w", err)
 	}
 
+	plan, err := migrateState(path, data)
+	if err != nil {
+		return nil, err
+	}
+
 	var state State
-	if err := json.Unmarshal(data, &state); err != nil {
+	if err := json.Unmarshal(plan.data, &state); err != nil {
 		return nil, fmt.Errorf("failed to parse state file: %w", err)
 	}
 
 	if state.Groups == nil {
 		state.Groups = make(map[string]*GroupState)
 	}
+	if state.Setup == nil {
+		state.Setup = make(map[string]string)
+	}
 
 	return &state, nil
 }
 
-// Save writes the state to the specified path
+// Save writes the state to the specified path. Concurrent saves are
+// serialized and the file is replaced atomically.
 func (s *State) Save(path string) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
 
+	s.mu.RLock()
 	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.RUnlock()
 	if err != nil {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
@@ -67,7 +239,11 @@ func (s *State) Save(path string) error {
 		return fmt.Errorf("failed to create state directory: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
@@ -81,6 +257,18 @@ func (s *State) GetGroup(name string) *GroupState {
 	return s.Groups[name]
 }
 
+// GroupStates returns a snapshot of the state of every group
+func (s *State) GroupStates() map[string]*GroupState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make(map[string]*GroupState, len(s.Groups))
+	for name, groupState := range s.Groups {
+		groups[name] = groupState
+	}
+	return groups
+}
+
 // SetGroup sets the state for a specific group
 func (s *State) SetGroup(name string, state *GroupState) {
 	s.mu.Lock()
@@ -95,20 +283,197 @@ func (s *State) DeleteGroup(name string) {
 	delete(s.Groups, name)
 }
 
-// GetStatePath returns the path to the grappler state file
-func GetStatePath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join(".grappler", "state.json")
+// SharedSidecar returns the running shared sidecar with a name, or nil
+func (s *State) SharedSidecar(name string) *SidecarState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Sidecars[name]
+}
+
+// SetSharedSidecar records a shared sidecar started for a group
+func (s *State) SetSharedSidecar(name string, sidecar *SidecarState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Sidecars == nil {
+		s.Sidecars = make(map[string]*SidecarState)
 	}
-	return filepath.Join(home, ".grappler", "state.json")
+	s.Sidecars[name] = sidecar
 }
 
-// GetLogsDir returns the path to the grappler logs directory
-func GetLogsDir() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join(".grappler", "logs")
+// AttachSidecar records that a group uses a running shared sidecar
+func (s *State) AttachSidecar(name, groupName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sidecar := s.Sidecars[name]
+	if sidecar == nil {
+		return
+	}
+	for _, g := range sidecar.Groups {
+		if g == groupName {
+			return
+		}
+	}
+	sidecar.Groups = append(sidecar.Groups, groupName)
+	sort.Strings(sidecar.Groups)
+}
+
+// DetachSidecar records that a group no longer uses a shared sidecar. When
+// no group is left the sidecar is removed and returned, for the caller to
+// stop.
+func (s *State) DetachSidecar(name, groupName string) *SidecarState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sidecar := s.Sidecars[name]
+	if sidecar == nil {
+		return nil
+	}
+
+	var groups []string
+	for _, g := range sidecar.Groups {
+		if g != groupName {
+			groups = append(groups, g)
+		}
+	}
+	sidecar.Groups = groups
+	if len(groups) > 0 {
+		return nil
+	}
+	delete(s.Sidecars, name)
+	return sidecar
+}
+
+// SharedSidecars returns a snapshot of the running shared sidecars
+func (s *State) SharedSidecars() map[string]*SidecarState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sidecars := make(map[string]*SidecarState, len(s.Sidecars))
+	for name, sidecar := range s.Sidecars {
+		sidecars[name] = sidecar
 	}
-	return filepath.Join(home, ".grappler", "logs")
+	return sidecars
+}
+
+// SetupMarker returns the recorded setup hash for a key
+func (s *State) SetupMarker(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Setup[key]
+}
+
+// MarkSetup records that setup hooks with the given hash completed for a key
+func (s *State) MarkSetup(key, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Setup[key] = hash
+}
+
+// ClearSetup removes the setup marker for a key
+func (s *State) ClearSetup(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Setup, key)
+}
+
+// RecordBoot records the current boot ID. If the system restarted since it
+// was last recorded, the groups recorded as running are stale: they are
+// moved to Resume without their sidecars, the shared sidecars are
+// forgotten, and the group names are returned. It reports whether the state
+// changed.
+func (s *State) RecordBoot(bootID string) (bool, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.BootID == bootID {
+		return false, nil
+	}
+
+	var stale []string
+	if s.BootID != "" {
+		for name, groupState := range s.Groups {
+			if !groupState.Running {
+				continue
+			}
+
+			// Its sidecars died with the boot; resume starts them afresh
+			groupState.Sidecars = nil
+
+			var services []string
+			for _, svc := range groupState.ServiceNames() {
+				if !groupState.IsStopped(svc) {
+					services = append(services, svc)
+				}
+			}
+
+			if s.Resume == nil {
+				s.Resume = make(map[string]*SessionGroup)
+			}
+			s.Resume[name] = &SessionGroup{
+				Services: services,
+				Ports:    groupState.Ports(),
+				Env:      groupState.Env,
+			}
+			delete(s.Groups, name)
+			stale = append(stale, name)
+		}
+		sort.Strings(stale)
+	}
+
+	// Shared sidecars died with the boot too, and their PIDs may have been
+	// reused since
+	s.Sidecars = nil
+	s.BootID = bootID
+	return true, stale
+}
+
+// ResumeGroups returns a snapshot of the groups waiting to be resumed
+func (s *State) ResumeGroups() map[string]*SessionGroup {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make(map[string]*SessionGroup, len(s.Resume))
+	for name, group := range s.Resume {
+		groups[name] = group
+	}
+	return groups
+}
+
+// ClearResume removes a group from the groups waiting to be resumed
+func (s *State) ClearResume(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Resume, name)
+}
+
+// GetStatePath returns the path to the state file of the active profile
+func GetStatePath() string {
+	return filepath.Join(HomeDir(), "state.json")
+}
+
+// GetLogsDir returns the path to the logs directory of the active profile
+func GetLogsDir() string {
+	return filepath.Join(HomeDir(), "logs")
+}
+
+// GetDataDir returns the path to the data directory of the active profile,
+// which holds a directory per group and service
+func GetDataDir() string {
+	return filepath.Join(HomeDir(), "data")
+}
+
+// DataDir returns the data directory of a service of a group
+func DataDir(groupName, serviceName string) string {
+	return filepath.Join(GetDataDir(), groupName, serviceName)
+}
+
+// SharedDataDir returns the data directory of a shared sidecar, which
+// outlives any one group
+func SharedDataDir(name string) string {
+	return filepath.Join(GetDataDir(), "_shared", name)
+}
+
+// GetRunDir returns the path to the runtime directory of the active profile,
+// which holds generated launch scripts and layouts
+func GetRunDir() string {
+	return filepath.Join(HomeDir(), "run")
 }
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// Templates are shared definitions that groups and services pull in with
+// extends, so a change to them applies to every group using them
+type Templates struct {
+	Groups   map[string]*Group   `yaml:"groups,omitempty"`
+	Services map[string]*Service `yaml:"services,omitempty"`
+}
+
+// applyTemplates replaces every group and service that extends a template
+// with its definition merged over the template's. Templates may extend
+// other templates of their kind.
+func (c *Config) applyTemplates() error {
+	r := &templateResolver{
+		config:   c,
+		v:        &validator{config: c, file: c.file},
+		groups:   make(map[string]*Group),
+		services: make(map[string]*Service),
+	}
+
+	for _, groupName := range sortedGroupNames(c.Groups) {
+		if group := c.Groups[groupName]; group != nil {
+			c.Groups[groupName] = r.group(joinPath("groups", groupName), group)
+		}
+	}
+
+	if len(r.v.problems) > 0 {
+		sortProblems(r.v.problems)
+		return &ValidationError{Problems: r.v.problems}
+	}
+	return nil
+}
+
+type templateResolver struct {
+	config *Config
+	v      *validator
+	// Resolved templates, by name
+	groups   map[string]*Group
+	services map[string]*Service
+}
+
+// group returns a group with the templates it and its services extend
+// applied
+func (r *templateResolver) group(path string, group *Group) *Group {
+	if group.Extends != "" {
+		if base := r.groupTemplate(group.Extends, path+".extends", nil); base != nil {
+			group = extendGroup(base, group)
+		}
+	}
+
+	resolved := *group
+	resolved.Extends = ""
+	for _, svc := range group.ServiceList() {
+		if svc.Service.Extends == "" {
+			continue
+		}
+		svcPath := joinPath(path, svc.Name)
+		if base := r.serviceTemplate(svc.Service.Extends, svcPath+".extends", nil); base != nil {
+			merged := extendService(base, svc.Service)
+			merged.Extends = ""
+			resolved.SetService(svc.Name, merged)
+		}
+	}
+	return &resolved
+}
+
+// groupTemplate returns a group template with the templates it extends
+// applied, or nil after recording a problem at refPath
+func (r *templateResolver) groupTemplate(name, refPath string, seen []string) *Group {
+	if resolved, ok := r.groups[name]; ok {
+		return resolved
+	}
+
+	var templates map[string]*Group
+	if r.config.Templates != nil {
+		templates = r.config.Templates.Groups
+	}
+	template, ok := templates[name]
+	switch {
+	case !ok:
+		r.v.addf(refPath, "unknown group template %q%s", name, suggest(name, templates))
+		return nil
+	case template == nil:
+		r.v.addf(joinPath("templates.groups", name), "empty template")
+		return nil
+	case slices.Contains(seen, name):
+		r.v.addf(refPath, "template cycle: %s → %s", strings.Join(seen, " → "), name)
+		return nil
+	}
+
+	resolved := template
+	if template.Extends != "" {
+		base := r.groupTemplate(template.Extends, joinPath("templates.groups", name)+".extends", append(seen, name))
+		if base == nil {
+			return nil
+		}
+		resolved = extendGroup(base, template)
+	}
+
+	copied := *resolved
+	copied.Extends = ""
+	r.groups[name] = &copied
+	return &copied
+}
+
+// serviceTemplate returns a service template with the templates it extends
+// applied, or nil after recording a problem at refPath
+func (r *templateResolver) serviceTemplate(name, refPath string, seen []string) *Service {
+	if resolved, ok := r.services[name]; ok {
+		return resolved
+	}
+
+	var templates map[string]*Service
+	if r.config.Templates != nil {
+		templates = r.config.Templates.Services
+	}
+	template, ok := templates[name]
+	switch {
+	case !ok:
+		r.v.addf(refPath, "unknown service template %q%s", name, suggest(name, templates))
+		return nil
+	case template == nil:
+		r.v.addf(joinPath("templates.services", name), "empty template")
+		return nil
+	case slices.Contains(seen, name):
+		r.v.addf(refPath, "template cycle: %s → %s", strings.Join(seen, " → "), name)
+		return nil
+	}
+
+	resolved := template
+	if template.Extends != "" {
+		base := r.serviceTemplate(template.Extends, joinPath("templates.services", name)+".extends", append(seen, name))
+		if base == nil {
+			return nil
+		}
+		resolved = extendService(base, template)
+	}
+
+	copied := *resolved
+	copied.Extends = ""
+	r.services[name] = &copied
+	return &copied
+}
+
+// extendGroup layers a group over the template it extends. Labels are
+// merged key by key, services as by extendService, hooks stage by stage,
+// and env files of both are loaded, the template's first. A database block
+// replaces the template's, and sidecars replace the template's of the same
+// name.
+func extendGroup(base, override *Group) *Group {
+	merged := *override
+	merged.Labels = mergeMaps(base.Labels, override.Labels)
+	merged.Backend = extendSlot(base.Backend, override.Backend)
+	merged.Frontend = extendSlot(base.Frontend, override.Frontend)
+	merged.Hooks = mergeHooks(base.Hooks, override.Hooks)
+	if len(base.EnvFiles) > 0 {
+		merged.EnvFiles = append(append([]EnvFile(nil), base.EnvFiles...), override.EnvFiles...)
+	}
+	if merged.Database == nil {
+		merged.Database = base.Database
+	}
+	if len(base.Sidecars) > 0 {
+		merged.Sidecars = make(map[string]*Sidecar, len(base.Sidecars)+len(override.Sidecars))
+		for name, sidecar := range base.Sidecars {
+			merged.Sidecars[name] = sidecar
+		}
+		for name, sidecar := range override.Sidecars {
+			merged.Sidecars[name] = sidecar
+		}
+	}
+	if merged.Extends == "" {
+		merged.Extends = base.Extends
+	}
+	return &merged
+}
+
+// extendSlot merges the service a group defines for a role over the one
+// its template defines, if either does
+func extendSlot(base, override *Service) *Service {
+	switch {
+	case base == nil:
+		return override
+	case override == nil:
+		return extendService(base, &Service{})
+	}
+	return extendService(base, override)
+}
+
+// extendService layers a service over the template it extends, as
+// MergeService does, except that the directory, branch and the template
+// the service extends are inherited too
+func extendService(base, override *Service) *Service {
+	merged := MergeService(base, override)
+	if merged.Directory == "" {
+		merged.Directory = base.Directory
+	}
+	if merged.Branch == "" {
+		merged.Branch = base.Branch
+	}
+	if merged.Extends == "" {
+		merged.Extends = base.Extends
+	}
+	return merged
+}
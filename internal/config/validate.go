@@ -0,0 +1,588 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is something wrong with a config file, at the position of the
+// value it concerns when that is known
+type Problem struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	b.WriteString(p.File)
+	if p.Line > 0 {
+		fmt.Fprintf(&b, ":%d", p.Line)
+		if p.Column > 0 {
+			fmt.Fprintf(&b, ":%d", p.Column)
+		}
+	}
+	b.WriteString(": ")
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// ValidationError is returned when config files have problems
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = p.String()
+	}
+	if len(lines) == 1 {
+		return "invalid config: " + lines[0]
+	}
+	return fmt.Sprintf("invalid config (%d problems):\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+// position is where a value was read from
+type position struct {
+	line   int
+	column int
+}
+
+// decodeStrict decodes YAML into out, rejecting unknown fields and
+// duplicate keys. Every problem is reported with its line and column. It
+// returns the position of each value, keyed by its dotted path.
+func decodeStrict(file string, data []byte, out interface{}) (map[string]position, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, &ValidationError{Problems: yamlProblems(file, err)}
+	}
+
+	d := &strictDecoder{file: file, positions: make(map[string]position)}
+	if len(root.Content) > 0 {
+		d.check(root.Content[0], reflect.TypeOf(out).Elem(), "")
+	}
+
+	if err := root.Decode(out); err != nil {
+		for _, p := range yamlProblems(file, err) {
+			// Duplicate keys are already reported with their column
+			if !duplicateKey.MatchString(p.Message) {
+				d.problems = append(d.problems, p)
+			}
+		}
+	}
+	if len(d.problems) > 0 {
+		sortProblems(d.problems)
+		return nil, &ValidationError{Problems: d.problems}
+	}
+	return d.positions, nil
+}
+
+type strictDecoder struct {
+	file      string
+	problems  []Problem
+	positions map[string]position
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// check walks node alongside the Go type it decodes into, reporting keys
+// the type has no field for
+func (d *strictDecoder) check(node *yaml.Node, t reflect.Type, path string) {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	d.positions[path] = position{line: node.Line, column: node.Column}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Types that decode themselves are only checked when written as a
+	// mapping of their tagged fields
+	if reflect.PointerTo(t).Implements(unmarshalerType) && (t.Kind() != reflect.Struct || !hasYAMLTags(t)) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		d.checkKeys(node, path, func(key string, keyNode, value *yaml.Node) {
+			field, ok := fields[key]
+			if !ok {
+				d.addf(keyNode, path, "unknown field %q%s", key, suggest(key, fields))
+				return
+			}
+			d.check(value, field, joinPath(path, key))
+		})
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		d.checkKeys(node, path, func(key string, _, value *yaml.Node) {
+			d.check(value, t.Elem(), joinPath(path, key))
+		})
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			d.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// checkKeys calls fn for each key of a mapping, reporting duplicates
+func (d *strictDecoder) checkKeys(node *yaml.Node, path string, fn func(key string, keyNode, value *yaml.Node)) {
+	seen := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, value := node.Content[i], node.Content[i+1]
+		key := keyNode.Value
+		if first, ok := seen[key]; ok {
+			d.addf(keyNode, path, "duplicate key %q (first defined on line %d)", key, first.Line)
+			continue
+		}
+		seen[key] = keyNode
+		fn(key, keyNode, value)
+	}
+}
+
+func (d *strictDecoder) addf(node *yaml.Node, path, format string, args ...interface{}) {
+	d.problems = append(d.problems, Problem{
+		File:    d.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// yamlFields returns the fields of a struct by YAML key, including inlined
+// structs
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for key, field := range yamlFields(f.Type) {
+				fields[key] = field
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func hasYAMLTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("yaml") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns a hint naming the field closest to a misspelled key
+func suggest[V any](key string, options map[string]V) string {
+	best, bestDist := "", 3
+	for name := range options {
+		if dist := editDistance(key, name); dist < bestDist || (dist == bestDist && name < best) {
+			best, bestDist = name, dist
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+var (
+	yamlLine     = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	duplicateKey = regexp.MustCompile(`^mapping key .* already defined`)
+)
+
+// yamlProblems converts a yaml.v3 error into problems, keeping the line
+// numbers it reports
+func yamlProblems(file string, err error) []Problem {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	problems := make([]Problem, len(messages))
+	for i, message := range messages {
+		problems[i] = Problem{File: file, Message: message}
+		if match := yamlLine.FindStringSubmatch(message); match != nil {
+			problems[i].Line, _ = strconv.Atoi(match[1])
+			problems[i].Message = match[2]
+		}
+	}
+	return problems
+}
+
+func uniqueProblems(problems []Problem) []Problem {
+	seen := make(map[Problem]bool)
+	var unique []Problem
+	for _, p := range problems {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+func sortProblems(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Validate checks a loaded config for problems decoding can't catch:
+// missing directories and commands, bad port ranges, references to unknown
+// services or other services' directories, and groups sharing a name
+func (c *Config) Validate(file string) []Problem {
+	v := &validator{config: c, file: file, databases: make(map[string]string)}
+
+	if c.Ports != nil {
+		v.checkPortRange("ports.backend", c.Ports.Backend)
+		v.checkPortRange("ports.frontend", c.Ports.Frontend)
+		v.checkPortRange("ports.sidecars", c.Ports.Sidecars)
+		ranges := []struct {
+			name string
+			r    PortRange
+		}{
+			{"backend", c.Ports.Backend},
+			{"frontend", c.Ports.Frontend},
+			{"sidecars", c.Ports.Sidecars},
+		}
+		for i, a := range ranges {
+			for _, b := range ranges[i+1:] {
+				if !a.r.IsZero() && !b.r.IsZero() && a.r.Start <= b.r.End && b.r.Start <= a.r.End {
+					v.addf("ports", "%s range %s overlaps %s range %s", a.name, a.r, b.name, b.r)
+				}
+			}
+		}
+	}
+
+	names := make(map[string]string)
+	for _, groupName := range sortedGroupNames(c.Groups) {
+		group := c.Groups[groupName]
+		path := joinPath("groups", groupName)
+		if group == nil {
+			v.addf(path, "empty group")
+			continue
+		}
+
+		if group.Name != "" {
+			if other, ok := names[group.Name]; ok {
+				v.addf(path+".name", "name %q is also used by group %q", group.Name, other)
+			} else {
+				names[group.Name] = groupName
+			}
+		}
+
+		if len(group.ServiceList()) == 0 {
+			v.addf(path, "group has no backend or frontend")
+			continue
+		}
+		// Without its repo configs the group is incomplete; report only why
+		if v.checkRepoConfigs(path, group) {
+			v.checkGroup(groupName, group, path)
+		}
+	}
+	v.checkSharedSidecars()
+
+	// Groups sharing a worktree report the problems of its repo config once each
+	v.problems = uniqueProblems(v.problems)
+	sortProblems(v.problems)
+	return v.problems
+}
+
+type validator struct {
+	config   *Config
+	file     string
+	problems []Problem
+	// databases maps the URL of each group's database to the group
+	databases map[string]string
+}
+
+func (v *validator) checkPortRange(path string, r PortRange) {
+	if r.IsZero() {
+		return
+	}
+	if r.Start < 1 || r.End > 65535 || r.Start > r.End {
+		v.addf(path, "invalid port range %s (ports are 1-65535, start first)", r)
+	}
+}
+
+// checkRepoConfigs reports why the repo configs of a group's worktrees
+// couldn't be applied, in the repo config files themselves where possible.
+// It returns whether they were.
+func (v *validator) checkRepoConfigs(path string, group *Group) bool {
+	err := group.RepoConfigErr()
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		v.problems = append(v.problems, invalid.Problems...)
+	} else if err != nil {
+		v.addf(path, "%v", err)
+	}
+	return err == nil
+}
+
+// checkGroup checks the services of a group and every reference in them
+func (v *validator) checkGroup(groupName string, group *Group, path string) {
+	// Sidecars get placeholder ports throughout, as a database may be on one
+	ports := make(map[string]int)
+	for name := range group.Sidecars {
+		ports[name] = 1
+	}
+
+	vars, err := NewVars(groupName, group, ports)
+	if err != nil {
+		if message, ok := strings.CutPrefix(err.Error(), "database: "); ok {
+			v.addf(path+".database", "%s", message)
+		} else if ref, message, ok := strings.Cut(err.Error(), ": "); ok && strings.HasPrefix(ref, "sidecars.") {
+			v.addf(path+"."+ref, "%s", message)
+		} else {
+			v.addf(path, "%v", err)
+		}
+		return
+	}
+	if vars.Database != nil {
+		v.checkDatabase(groupName, group, path, vars.Database)
+	}
+
+	// Resolve against placeholder ports to catch bad references
+	for _, svc := range group.ServiceList() {
+		ports[svc.Name] = 1
+	}
+	refVars, _ := NewVars(groupName, group, ports)
+	resolved, err := refVars.ResolveGroup(group)
+	if err != nil {
+		v.addf(path, "%v", err)
+	}
+
+	for _, name := range group.SidecarNames() {
+		v.checkSidecar(joinPath(path, "sidecars."+name), group.Sidecars[name])
+	}
+
+	for _, svc := range group.ServiceList() {
+		svcPath := joinPath(path, svc.Name)
+		service := svc.Service
+
+		dir := vars.Directory(svc.Name)
+		if dir == "" {
+			v.addf(svcPath, "no directory set")
+		} else if info, err := os.Stat(dir); err != nil {
+			v.addf(svcPath+".directory", "directory %s does not exist", dir)
+		} else if !info.IsDir() {
+			v.addf(svcPath+".directory", "%s is not a directory", dir)
+		}
+
+		if service.Command.IsZero() {
+			v.addf(svcPath, "no command set (add one to the worktree's %s or here)", RepoConfigFile)
+		}
+
+		for key, ref := range service.EnvFromServices {
+			name, field, ok := strings.Cut(ref, ".")
+			refPath := svcPath + ".env_from_services." + key
+			switch {
+			case !ok:
+				v.addf(refPath, "expected <service>.<field>, got %q", ref)
+			case group.Service(name) == nil:
+				v.addf(refPath, "group has no service %q", name)
+			case field != "port" && field != "url" && field != "directory" && field != "branch" && field != "data_dir":
+				v.addf(refPath, "unknown field %q (expected port, url, directory, branch or data_dir)", field)
+			}
+		}
+
+		if service.Data != nil {
+			v.checkData(groupName, svc.Name, svcPath+".data", service.Data)
+			if resolved != nil && dir != "" {
+				for i, seed := range resolved.Service(svc.Name).Data.Seed {
+					if _, err := os.Stat(SeedPath(dir, seed)); err != nil {
+						v.addf(fmt.Sprintf("%s.data.seed[%d]", svcPath, i), "%s does not exist", SeedPath(dir, seed))
+					}
+				}
+			}
+		}
+
+		if service.Health != nil && service.Health.Timeout != "" {
+			if _, err := time.ParseDuration(service.Health.Timeout); err != nil {
+				v.addf(svcPath+".health.timeout", "%v", err)
+			}
+		}
+	}
+}
+
+// checkSidecar checks a sidecar's command, scope and timeout
+func (v *validator) checkSidecar(path string, sidecar *Sidecar) {
+	if sidecar.Command.IsZero() {
+		v.addf(path, "no command set")
+	}
+	if sidecar.Scope != "" && sidecar.Scope != SidecarGroup && sidecar.Scope != SidecarShared {
+		v.addf(path+".scope", "unknown scope %q (expected group or shared)", sidecar.Scope)
+	}
+	if sidecar.Timeout != "" {
+		if _, err := time.ParseDuration(sidecar.Timeout); err != nil {
+			v.addf(path+".timeout", "%v", err)
+		}
+	}
+}
+
+// checkSharedSidecars checks that the groups sharing a sidecar agree on how
+// to run it, since whichever group starts first decides
+func (v *validator) checkSharedSidecars() {
+	first := make(map[string]string)
+	for _, groupName := range sortedGroupNames(v.config.Groups) {
+		group := v.config.Groups[groupName]
+		if group == nil {
+			continue
+		}
+		for _, name := range group.SidecarNames() {
+			sidecar := group.Sidecars[name]
+			if sidecar == nil || !sidecar.Shared() {
+				continue
+			}
+			other, ok := first[name]
+			if !ok {
+				first[name] = groupName
+				continue
+			}
+			base := v.config.Groups[other].Sidecars[name]
+			if sidecar.Command.String() != base.Command.String() || sidecar.Directory != base.Directory || !reflect.DeepEqual(sidecar.Env, base.Env) {
+				v.addf(joinPath("groups", groupName)+".sidecars."+name, "shared sidecar %q runs differently in group %q (shared sidecars need the same command, directory and env everywhere)", name, other)
+			}
+		}
+	}
+}
+
+// checkDatabase checks that a group's database isn't also another group's,
+// as names that differ only in case or punctuation resolve to the same one
+func (v *validator) checkDatabase(groupName string, group *Group, path string, db *DatabaseVars) {
+	// Groups' own sidecars are distinct servers, whatever their placeholder ports
+	for _, name := range group.SidecarNames() {
+		if !group.Sidecars[name].Shared() && strings.Contains(group.Database.URL, "${sidecars."+name+".") {
+			return
+		}
+	}
+
+	// Without a database resolver there are no URLs to compare
+	if db.URL == "" {
+		return
+	}
+	if other, ok := v.databases[db.URL]; ok {
+		v.addf(path+".database", "database %q is also used by group %q", db.Name, other)
+		return
+	}
+	v.databases[db.URL] = groupName
+}
+
+// checkData checks that a service seeds its data from another group that
+// has the same service
+func (v *validator) checkData(groupName, serviceName, path string, data *Data) {
+	if data.SeedFrom == "" {
+		return
+	}
+	source, ok := v.config.Groups[data.SeedFrom]
+	switch {
+	case data.SeedFrom == groupName:
+		v.addf(path+".seed_from", "a group can't seed its data from itself")
+	case !ok:
+		v.addf(path+".seed_from", "unknown group %q%s", data.SeedFrom, suggest(data.SeedFrom, v.config.Groups))
+	case source == nil || source.Service(serviceName) == nil:
+		v.addf(path+".seed_from", "group %q has no %s", data.SeedFrom, serviceName)
+	}
+}
+
+// addf records a problem at the position of path, or of its closest parent
+// with a known position
+func (v *validator) addf(path, format string, args ...interface{}) {
+	p := Problem{File: v.file, Path: path, Message: fmt.Sprintf(format, args...)}
+	for at := path; ; {
+		if pos, ok := v.config.positions[at]; ok {
+			p.Line, p.Column = pos.line, pos.column
+			break
+		}
+		i := strings.LastIndexAny(at, ".[")
+		if i < 0 {
+			break
+		}
+		at = at[:i]
+	}
+	v.problems = append(v.problems, p)
+}
+
+func sortedGroupNames(groups map[string]*Group) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+const defaultPostgresURL = "postgres://localhost:5432/postgres"
+
+// postgres manages databases, or schemas of one database, on a Postgres
+// server through psql
+type postgres struct {
+	admin  *url.URL
+	schema bool
+}
+
+func newPostgres(spec Spec) (Provider, error) {
+	raw := spec.URL
+	if raw == "" {
+		raw = defaultPostgresURL
+	}
+	admin, err := url.Parse(raw)
+	if err != nil || (admin.Scheme != "postgres" && admin.Scheme != "postgresql") {
+		return nil, fmt.Errorf("invalid postgres URL %q (expected postgres://user@host:port/database)", raw)
+	}
+	return &postgres{admin: admin, schema: spec.Schema}, nil
+}
+
+func (p *postgres) Exists(name string) (bool, error) {
+	query := "SELECT 1 FROM pg_database WHERE datname = " + quoteLiteral(name)
+	if p.schema {
+		query = "SELECT 1 FROM pg_namespace WHERE nspname = " + quoteLiteral(name)
+	}
+	out, err := p.psql(query)
+	if err != nil {
+		return false, err
+	}
+	return out == "1", nil
+}
+
+func (p *postgres) Create(name, template string) error {
+	if p.schema {
+		if template != "" {
+			return fmt.Errorf("postgres can't copy a template into a schema; drop schema: true to clone databases")
+		}
+		_, err := p.psql("CREATE SCHEMA " + quoteIdentifier(name))
+		return err
+	}
+
+	statement := "CREATE DATABASE " + quoteIdentifier(name)
+	if template != "" {
+		statement += " TEMPLATE " + quoteIdentifier(template)
+	}
+	_, err := p.psql(statement)
+	return err
+}
+
+func (p *postgres) Drop(name string) error {
+	statement := "DROP DATABASE IF EXISTS " + quoteIdentifier(name)
+	if p.schema {
+		statement = "DROP SCHEMA IF EXISTS " + quoteIdentifier(name) + " CASCADE"
+	}
+	_, err := p.psql(statement)
+	return err
+}
+
+func (p *postgres) URL(name string) (string, error) {
+	u := *p.admin
+	if p.schema {
+		query := u.Query()
+		query.Set("options", "-csearch_path="+name)
+		u.RawQuery = query.Encode()
+	} else {
+		u.Path = "/" + name
+	}
+	return u.String(), nil
+}
+
+// psql runs a statement against the admin database
+func (p *postgres) psql(statement string) (string, error) {
+	return run(exec.Command("psql", p.admin.String(), "-v", "ON_ERROR_STOP=1", "-tAc", statement))
+}
+
+// quoteIdentifier quotes a Postgres identifier
+func quoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
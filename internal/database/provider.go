@@ -0,0 +1,88 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Provider creates and drops the databases of groups on a local database
+// server, or as local files
+type Provider interface {
+	// Exists reports whether the database is there
+	Exists(name string) (bool, error)
+	// Create creates the database, as a copy of template when it is set
+	Create(name, template string) error
+	// Drop removes the database, doing nothing if it doesn't exist
+	Drop(name string) error
+	// URL returns the connection URL services use for the database
+	URL(name string) (string, error)
+}
+
+// Spec configures a provider
+type Spec struct {
+	// URL is the admin connection URL of the server; empty for the
+	// provider's default
+	URL string
+	// Schema isolates groups with a schema in the URL's database rather
+	// than a database of their own
+	Schema bool
+	// Dir is the directory file-based providers keep databases in
+	Dir string
+}
+
+// Factory returns a provider for a spec
+type Factory func(spec Spec) (Provider, error)
+
+var providers = map[string]Factory{
+	"postgres": newPostgres,
+	"mysql":    newMySQL,
+	"sqlite":   newSQLite,
+}
+
+// Register adds a provider, or replaces one, under a name
+func Register(name string, factory Factory) {
+	providers[name] = factory
+}
+
+// New returns the provider registered under a name
+func New(name string, spec Spec) (Provider, error) {
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (expected %s)", name, strings.Join(Names(), ", "))
+	}
+	return factory(spec)
+}
+
+// Names returns the names of the registered providers, sorted
+func Names() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run runs a client command, returning its output or an error carrying
+// what it printed on stderr
+func run(cmd *exec.Cmd) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", cmd.Args[0], msg)
+		}
+		return "", fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// quoteLiteral quotes a string as an SQL literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
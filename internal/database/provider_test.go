@@ -0,0 +1,77 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeScript stands in for the database clients. Each call logs its name,
+// arguments and MYSQL_PWD, plus whatever mysql reads on stdin when it runs
+// no statement. It prints $FAKE_OUTPUT, mysqldump a dump, and with
+// $FAKE_FAIL set it fails like a client that can't reach its server.
+const fakeScript = `#!/bin/sh
+name=$(basename "$0")
+{
+	printf '%s' "$name"
+	for arg in "$@"; do printf ' [%s]' "$arg"; done
+	[ -n "$MYSQL_PWD" ] && printf ' pwd=%s' "$MYSQL_PWD"
+	echo
+} >> "$FAKE_LOG"
+if [ -n "$FAKE_FAIL" ]; then
+	echo "could not connect to server" >&2
+	exit 2
+fi
+case "$name" in
+mysqldump) echo "-- dump of $*" ;;
+mysql) case " $* " in *" -e "*) ;; *) sed 's/^/stdin: /' >> "$FAKE_LOG" ;; esac ;;
+esac
+printf '%s' "$FAKE_OUTPUT"
+`
+
+// fakeClients puts stand-ins for psql, mysql and mysqldump first in PATH
+// and returns a function reading what they were called with, one line per
+// call
+func fakeClients(t *testing.T) func() []string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"psql", "mysql", "mysqldump"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(fakeScript), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log := filepath.Join(dir, "calls.log")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_LOG", log)
+	t.Setenv("FAKE_OUTPUT", "")
+	t.Setenv("FAKE_FAIL", "")
+	t.Setenv("MYSQL_PWD", "")
+
+	return func() []string {
+		data, err := os.ReadFile(log)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(log)
+		return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+}
+
+func assertCalls(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	_, err := New("oracle", Spec{})
+	if err == nil || !strings.Contains(err.Error(), "mysql, postgres, sqlite") {
+		t.Fatalf("New() error = %v, want one listing the providers", err)
+	}
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// sqlite keeps each database as a file in a directory. An empty file is a
+// valid empty SQLite database, so no client is needed.
+type sqlite struct {
+	dir string
+}
+
+func newSQLite(spec Spec) (Provider, error) {
+	switch {
+	case spec.Schema:
+		return nil, fmt.Errorf("sqlite has no schemas; drop schema: true")
+	case spec.URL != "":
+		return nil, fmt.Errorf("sqlite keeps databases in the group's data directory and takes no url")
+	}
+	return &sqlite{dir: spec.Dir}, nil
+}
+
+func (s *sqlite) path(name string) string {
+	return filepath.Join(s.dir, name+".sqlite3")
+}
+
+func (s *sqlite) Exists(name string) (bool, error) {
+	_, err := os.Stat(s.path(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Create creates an empty database file, or copies the template, which is
+// a path to an SQLite file
+func (s *sqlite) Create(name, template string) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", s.dir, err)
+	}
+
+	out, err := os.OpenFile(s.path(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if template == "" {
+		return out.Close()
+	}
+
+	in, err := os.Open(template)
+	if err == nil {
+		_, err = io.Copy(out, in)
+		in.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(s.path(name))
+		return fmt.Errorf("failed to copy template: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlite) Drop(name string) error {
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if err := os.Remove(s.path(name) + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlite) URL(name string) (string, error) {
+	return "sqlite://" + s.path(name), nil
+}
@@ -0,0 +1,510 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+	"gopkg.in/yaml.v3"
+)
+
+// Health check polling used when the compose file doesn't set it. Compose
+// defaults to 30s intervals, far too slow for a local start.
+const (
+	defaultHealthInterval = time.Second
+	defaultHealthRetries  = 30
+)
+
+// ReadCompose reads the services of a docker-compose file. Services that
+// build from source and set a command run directly on the host; services
+// that only run an image are kept so dependents can start them with docker.
+func ReadCompose(path string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	services := resolveAlias(mappingValue(documentNode(&root), "services"))
+	if services == nil || services.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("no services found in %s", path)
+	}
+
+	result := &Result{}
+	for _, pair := range mappingPairs(services) {
+		result.Processes = append(result.Processes, readComposeService(result, dir, pair[0].Value, pair[1]))
+	}
+	return result, nil
+}
+
+// readComposeService translates one compose service, reporting the keys it
+// can't map
+func readComposeService(result *Result, dir, name string, node *yaml.Node) *Process {
+	p := &Process{
+		Name:    name,
+		Service: &config.Service{Directory: dir},
+		Dir:     dir,
+	}
+	if node = resolveAlias(node); node.Kind != yaml.MappingNode {
+		result.warnf("%s: line %d: expected a mapping", name, node.Line)
+		return p
+	}
+
+	var command, entrypoint *yaml.Node
+	var ports []*yaml.Node
+	var unmapped []string
+	hasBuild := false
+
+	for _, pair := range mappingPairs(node) {
+		key, value := pair[0].Value, resolveAlias(pair[1])
+		var err error
+
+		switch key {
+		case "image":
+			p.Image = value.Value
+		case "build":
+			hasBuild = true
+			context := value.Value
+			if value.Kind == yaml.MappingNode {
+				context = "."
+				if v := mappingValue(value, "context"); v != nil {
+					context = v.Value
+				}
+			}
+			if !filepath.IsAbs(context) {
+				context = filepath.Join(dir, context)
+			}
+			p.Dir = context
+			p.Service.Directory = context
+		case "command":
+			command = value
+		case "entrypoint":
+			entrypoint = value
+		case "environment":
+			p.Service.Env, err = composeEnvironment(value)
+		case "env_file":
+			p.Service.EnvFiles, err = composeEnvFiles(dir, value)
+		case "depends_on":
+			p.DependsOn, err = composeDependsOn(value)
+		case "healthcheck":
+			var hook string
+			if hook, err = composeHealthcheck(result, name, value); err == nil && hook != "" {
+				p.Service.Hooks = &config.Hooks{PostStart: []string{hook}}
+			}
+		case "ports", "expose":
+			if value.Kind != yaml.SequenceNode {
+				err = fmt.Errorf("expected a list")
+			}
+			ports = append(ports, value.Content...)
+		default:
+			if !strings.HasPrefix(key, "x-") {
+				unmapped = append(unmapped, key)
+			}
+		}
+
+		if err != nil {
+			result.warnf("%s: line %d: %s not mapped: %v", name, value.Line, key, err)
+		}
+	}
+
+	if len(unmapped) > 0 {
+		result.warnf("%s: not mapped: %s", name, strings.Join(unmapped, ", "))
+	}
+
+	if len(ports) > 0 {
+		port, published, err := composePort(ports[0])
+		if err != nil {
+			result.warnf("%s: line %d: port not mapped: %v", name, ports[0].Line, err)
+		}
+		p.Port, p.HostPort = port, published
+		if len(ports) > 1 {
+			result.warnf("%s: only the first port is mapped (grappler allocates one port per service)", name)
+		}
+	}
+
+	if command == nil && entrypoint == nil {
+		if hasBuild {
+			result.warnf("%s: no command (the image's CMD isn't read), not imported", name)
+		}
+		return p
+	}
+
+	cmd, err := composeCommand(entrypoint, command)
+	if err != nil {
+		result.warnf("%s: command not mapped: %v", name, err)
+		return p
+	}
+	if cmd.Line != "" {
+		cmd, p.Service.Shell = commandFromLine(cmd.Line)
+	}
+	p.Service.Command = cmd
+	p.Runnable = true
+	return p
+}
+
+// composeCommand joins an entrypoint and command, each a string or a list.
+// Lists stay lists; anything else becomes a command line.
+func composeCommand(entrypoint, command *yaml.Node) (config.Command, error) {
+	var parts [][]string
+	lists := true
+	for _, node := range []*yaml.Node{entrypoint, command} {
+		if node == nil {
+			continue
+		}
+		switch node.Kind {
+		case yaml.ScalarNode:
+			lists = false
+			parts = append(parts, []string{unescapeDollars(node.Value)})
+		case yaml.SequenceNode:
+			var args []string
+			if err := node.Decode(&args); err != nil {
+				return config.Command{}, err
+			}
+			for i, arg := range args {
+				args[i] = unescapeDollars(arg)
+			}
+			parts = append(parts, args)
+		default:
+			return config.Command{}, fmt.Errorf("line %d: expected a string or a list", node.Line)
+		}
+	}
+
+	if lists {
+		var args []string
+		for _, part := range parts {
+			args = append(args, part...)
+		}
+		return config.Command{Args: args}, nil
+	}
+
+	var words []string
+	for _, part := range parts {
+		if len(part) == 1 {
+			words = append(words, part[0])
+		} else {
+			words = append(words, process.QuoteArgv(part))
+		}
+	}
+	return config.NewCommand(strings.Join(words, " ")), nil
+}
+
+// composeEnvironment reads environment as a mapping or a list of KEY=value.
+// Bare names pass the host value through, which services get anyway.
+func composeEnvironment(node *yaml.Node) (map[string]string, error) {
+	env := make(map[string]string)
+	switch node.Kind {
+	case yaml.MappingNode:
+		for _, pair := range mappingPairs(node) {
+			if value := resolveAlias(pair[1]); value.Tag != "!!null" {
+				env[pair[0].Value] = composeValue(value.Value)
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if key, value, ok := strings.Cut(item.Value, "="); ok {
+				env[key] = composeValue(value)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("expected a mapping or a list")
+	}
+
+	if len(env) == 0 {
+		return nil, nil
+	}
+	return env, nil
+}
+
+// composeEnvFiles reads env_file as a path, a list of paths or a list of
+// {path, required} entries, resolving them against the compose directory
+func composeEnvFiles(dir string, node *yaml.Node) ([]config.EnvFile, error) {
+	items := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		items = node.Content
+	}
+
+	var files []config.EnvFile
+	for _, item := range items {
+		file := config.EnvFile{Path: item.Value}
+		if item.Kind == yaml.MappingNode {
+			var entry struct {
+				Path     string `yaml:"path"`
+				Required *bool  `yaml:"required"`
+			}
+			if err := item.Decode(&entry); err != nil {
+				return nil, err
+			}
+			file = config.EnvFile{Path: entry.Path, Optional: entry.Required != nil && !*entry.Required}
+		}
+		if file.Path == "" {
+			return nil, fmt.Errorf("line %d: expected a path", item.Line)
+		}
+		if !filepath.IsAbs(file.Path) {
+			file.Path = filepath.Join(dir, file.Path)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// composeDependsOn reads depends_on as a list or a mapping of conditions
+func composeDependsOn(node *yaml.Node) ([]string, error) {
+	var deps []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			deps = append(deps, item.Value)
+		}
+	case yaml.MappingNode:
+		for _, pair := range mappingPairs(node) {
+			deps = append(deps, pair[0].Value)
+		}
+	default:
+		return nil, fmt.Errorf("expected a list or a mapping")
+	}
+	return deps, nil
+}
+
+// composeHealthcheck turns a healthcheck into a post_start hook that polls
+// its test until it passes. Disabled checks return an empty hook.
+func composeHealthcheck(result *Result, name string, node *yaml.Node) (string, error) {
+	var check struct {
+		Test        yaml.Node `yaml:"test"`
+		Interval    string    `yaml:"interval"`
+		Timeout     string    `yaml:"timeout"`
+		Retries     int       `yaml:"retries"`
+		StartPeriod string    `yaml:"start_period"`
+		Disable     bool      `yaml:"disable"`
+	}
+	if err := node.Decode(&check); err != nil {
+		return "", err
+	}
+	if check.Disable {
+		return "", nil
+	}
+
+	var test string
+	switch check.Test.Kind {
+	case yaml.ScalarNode:
+		test = check.Test.Value
+	case yaml.SequenceNode:
+		var args []string
+		if err := check.Test.Decode(&args); err != nil {
+			return "", err
+		}
+		if len(args) == 0 {
+			return "", fmt.Errorf("empty test")
+		}
+		switch args[0] {
+		case "NONE":
+			return "", nil
+		case "CMD":
+			test = process.QuoteArgv(args[1:])
+		case "CMD-SHELL":
+			test = strings.Join(args[1:], " ")
+		default:
+			return "", fmt.Errorf("test must start with CMD, CMD-SHELL or NONE")
+		}
+	default:
+		return "", fmt.Errorf("no test")
+	}
+	if strings.TrimSpace(test) == "" {
+		return "", fmt.Errorf("empty test")
+	}
+
+	interval := defaultHealthInterval
+	if check.Interval != "" {
+		d, err := time.ParseDuration(check.Interval)
+		if err != nil {
+			return "", fmt.Errorf("interval: %w", err)
+		}
+		interval = d
+	}
+	retries := defaultHealthRetries
+	if check.Retries > 0 {
+		retries = check.Retries
+	}
+	if check.StartPeriod != "" {
+		d, err := time.ParseDuration(check.StartPeriod)
+		if err != nil {
+			return "", fmt.Errorf("start_period: %w", err)
+		}
+		retries += int(math.Ceil(float64(d) / float64(interval)))
+	}
+	if check.Timeout != "" {
+		result.warnf("%s: healthcheck timeout not mapped", name)
+	}
+
+	seconds := strconv.FormatFloat(interval.Seconds(), 'f', -1, 64)
+	return fmt.Sprintf(`i=0; until (%s); do i=$((i+1)); if [ "$i" -ge %d ]; then echo "health check failed" >&2; exit 1; fi; sleep %s; done`,
+		unescapeDollars(test), retries, seconds), nil
+}
+
+// composePort returns the port a service listens on and the host port it
+// is published on (0 if none) from a ports or expose entry: "8000",
+// "3000:8000", "127.0.0.1:3000:8000/tcp" or {target: 8000, published: 3000}
+func composePort(node *yaml.Node) (int, int, error) {
+	var target, published string
+	if node.Kind == yaml.MappingNode {
+		if v := mappingValue(node, "target"); v != nil {
+			target = v.Value
+		}
+		if v := mappingValue(node, "published"); v != nil {
+			published = v.Value
+		}
+	} else {
+		value, _, _ := strings.Cut(node.Value, "/")
+		parts := strings.Split(value, ":")
+		target = parts[len(parts)-1]
+		if len(parts) > 1 {
+			published = parts[len(parts)-2]
+		}
+	}
+
+	if strings.Contains(target, "-") || strings.Contains(published, "-") {
+		return 0, 0, fmt.Errorf("port ranges aren't supported")
+	}
+	port, err := strconv.Atoi(target)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q", node.Value)
+	}
+	hostPort, _ := strconv.Atoi(published)
+	return port, hostPort, nil
+}
+
+// composeValue translates compose interpolation in an env value into
+// grappler's: $VAR and ${VAR} read the host env, and $$ is a literal $
+func composeValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			// $${ must stay escaped for grappler
+			if i+2 < len(s) && s[i+2] == '{' {
+				b.WriteString("$$")
+			} else {
+				b.WriteByte('$')
+			}
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			expr := s[i+2 : i+end]
+			name, fallback := expr, ""
+			if j := strings.IndexAny(expr, ":-?"); j >= 0 {
+				name = expr[:j]
+				if rest := strings.TrimPrefix(expr[j:], ":"); strings.HasPrefix(rest, "-") {
+					fallback = rest[1:]
+				}
+			}
+			fmt.Fprintf(&b, "${env.%s:-%s}", name, fallback)
+			i += end
+		case next == '_' || isLetter(next):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || isLetter(s[j]) || (s[j] >= '0' && s[j] <= '9')) {
+				j++
+			}
+			fmt.Fprintf(&b, "${env.%s:-}", s[i+1:j])
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// unescapeDollars turns compose's $$ escape into the literal $ a command
+// expects
+func unescapeDollars(s string) string {
+	return strings.ReplaceAll(s, "$$", "$")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// documentNode returns the top-level node of a parsed document
+func documentNode(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// resolveAlias returns the node an alias points to
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// mappingPairs returns the key/value pairs of a mapping node with "<<" merge
+// keys expanded, in order. Keys set directly override merged ones.
+func mappingPairs(node *yaml.Node) [][2]*yaml.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var merged, direct [][2]*yaml.Node
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value != "<<" {
+			direct = append(direct, [2]*yaml.Node{key, value})
+			seen[key.Value] = true
+			continue
+		}
+
+		sources := []*yaml.Node{value}
+		if value = resolveAlias(value); value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, source := range sources {
+			merged = append(merged, mappingPairs(source)...)
+		}
+	}
+
+	var pairs [][2]*yaml.Node
+	for _, pair := range merged {
+		if !seen[pair[0].Value] {
+			pairs = append(pairs, pair)
+			seen[pair[0].Value] = true
+		}
+	}
+	return append(pairs, direct...)
+}
+
+// mappingValue returns the value for key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for _, pair := range mappingPairs(node) {
+		if pair[0].Value == key {
+			return resolveAlias(pair[1])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+	"github.com/kris-hansen/grappler/internal/process"
+)
+
+// Process is a process definition read from a Procfile or compose file
+type Process struct {
+	Name    string
+	Service *config.Service
+	// Dir is the directory the process builds from, or empty if unknown
+	Dir string
+	// Port is the port the process listens on, or 0 if unknown
+	Port int
+	// HostPort is the host port a compose service's port is published on
+	HostPort  int
+	DependsOn []string
+	// Image is the image a compose service runs
+	Image string
+	// Runnable is false for processes grappler can't run, such as compose
+	// services that only run an image
+	Runnable bool
+}
+
+// Result is an imported file: its processes in file order and anything in
+// it that couldn't be mapped
+type Result struct {
+	Processes []*Process
+	Warnings  []string
+}
+
+// Process returns the process with the given name, or nil
+func (r *Result) Process(name string) *Process {
+	for _, p := range r.Processes {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (r *Result) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Names preferred for each service slot, most likely first. "web" is the
+// server in a Procfile but usually the UI next to an "api".
+var (
+	backendNames  = []string{"backend", "api", "server", "web", "app"}
+	frontendNames = []string{"frontend", "client", "ui", "web"}
+)
+
+// AssignSlots maps processes to the backend and frontend services of a group,
+// returning process names keyed by service name. Empty backend or frontend
+// names are guessed from the process names; processes left over are
+// reported as unmapped.
+func (r *Result) AssignSlots(backend, frontend string) (map[string]string, error) {
+	taken := make(map[string]bool)
+	slots := make(map[string]string)
+
+	for _, slot := range []struct {
+		service string
+		name    string
+	}{{"backend", backend}, {"frontend", frontend}} {
+		if slot.name == "" {
+			continue
+		}
+		p := r.Process(slot.name)
+		if p == nil {
+			return nil, fmt.Errorf("no process named %q", slot.name)
+		}
+		if !p.Runnable {
+			return nil, fmt.Errorf("process %q has no command grappler can run", slot.name)
+		}
+		if taken[slot.name] {
+			return nil, fmt.Errorf("process %q can't be both backend and frontend", slot.name)
+		}
+		slots[slot.service] = slot.name
+		taken[slot.name] = true
+	}
+
+	if backend == "" {
+		if name := r.guess(backendNames, taken); name != "" {
+			slots["backend"] = name
+			taken[name] = true
+		}
+	}
+	if frontend == "" {
+		if name := r.guess(frontendNames, taken); name != "" {
+			slots["frontend"] = name
+			taken[name] = true
+		}
+	}
+
+	// A lone process with an unfamiliar name still makes a backend
+	if len(slots) == 0 {
+		for _, p := range r.Processes {
+			if p.Runnable {
+				slots["backend"] = p.Name
+				taken[p.Name] = true
+				break
+			}
+		}
+	}
+
+	for _, p := range r.Processes {
+		if !taken[p.Name] && p.Runnable {
+			r.warnf("%s: not imported (a group runs one backend and one frontend; pick them with --backend and --frontend)", p.Name)
+		}
+	}
+
+	return slots, nil
+}
+
+// guess returns the first free runnable process matching a preferred name,
+// exactly or as part of its name
+func (r *Result) guess(names []string, taken map[string]bool) string {
+	for _, exact := range []bool{true, false} {
+		for _, want := range names {
+			for _, p := range r.Processes {
+				if taken[p.Name] || !p.Runnable {
+					continue
+				}
+				if p.Name == want || (!exact && strings.Contains(p.Name, want)) {
+					return p.Name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Services translates the assigned processes into grappler services keyed by
+// service name. Hardcoded ports become ${port}, and addresses of other
+// imported processes become references to their allocated ports.
+func (r *Result) Services(slots map[string]string) map[string]*config.Service {
+	serviceOf := make(map[string]string, len(slots))
+	for service, name := range slots {
+		serviceOf[name] = service
+	}
+
+	services := make(map[string]*config.Service, len(slots))
+	for _, service := range sortedKeys(slots) {
+		p := r.Process(slots[service])
+		svc := p.Service
+
+		// Image dependencies are reached on the host through their
+		// published ports
+		for _, name := range r.ImageDependencies(slots) {
+			dep := r.Process(name)
+			if dep.Port == 0 {
+				continue
+			}
+			hostAddress := fmt.Sprintf("%s:%d", name, dep.Port)
+			if dep.HostPort > 0 {
+				hostAddress = fmt.Sprintf("localhost:%d", dep.HostPort)
+			}
+			address := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `:` + strconv.Itoa(dep.Port) + `\b`)
+			if rewriteService(svc, address, hostAddress) && dep.HostPort == 0 {
+				r.warnf("%s: uses %s:%d, which isn't published to the host", p.Name, name, dep.Port)
+			}
+		}
+		for name, other := range serviceOf {
+			if peer := r.Process(name); peer.Port > 0 {
+				address := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `:` + strconv.Itoa(peer.Port) + `\b`)
+				rewriteService(svc, address, fmt.Sprintf("localhost:${services.%s.port}", other))
+			}
+		}
+		if p.Port > 0 {
+			own := regexp.MustCompile(`\b` + strconv.Itoa(p.Port) + `\b`)
+			if !rewriteService(svc, own, "${port}") {
+				if svc.Env == nil {
+					svc.Env = make(map[string]string)
+				}
+				if _, set := svc.Env["PORT"]; !set {
+					svc.Env["PORT"] = "${port}"
+				}
+				r.warnf("%s: port %d isn't in its command or env; set PORT=${port}, check that it listens there", p.Name, p.Port)
+			}
+		}
+
+		for _, dep := range p.DependsOn {
+			switch depService, ok := serviceOf[dep]; {
+			case !ok:
+				if d := r.Process(dep); d == nil || d.Runnable {
+					r.warnf("%s: depends_on %s, which isn't imported", p.Name, dep)
+				}
+			case service == "backend" && depService == "frontend":
+				r.warnf("%s: depends_on %s can't be honored (grappler starts the backend first)", p.Name, dep)
+			}
+		}
+
+		services[service] = svc
+	}
+
+	deps := make(map[string]bool)
+	for _, name := range r.ImageDependencies(slots) {
+		deps[name] = true
+	}
+	for _, p := range r.Processes {
+		if p.Image != "" && !p.Runnable && !deps[p.Name] {
+			r.warnf("%s: runs only the image %s, not imported", p.Name, p.Image)
+		}
+	}
+
+	return services
+}
+
+// ImageDependencies returns the processes that only run an image and that
+// the assigned processes depend on, in file order
+func (r *Result) ImageDependencies(slots map[string]string) []string {
+	needed := make(map[string]bool)
+	for _, name := range slots {
+		for _, dep := range r.Process(name).DependsOn {
+			needed[dep] = true
+		}
+	}
+
+	var deps []string
+	for _, p := range r.Processes {
+		if needed[p.Name] && !p.Runnable {
+			deps = append(deps, p.Name)
+		}
+	}
+	return deps
+}
+
+// rewriteService replaces matches of pattern in a service's command, env
+// and hooks, reporting whether there were any
+func rewriteService(svc *config.Service, pattern *regexp.Regexp, replacement string) bool {
+	matched := false
+	apply := func(s string) string {
+		if !pattern.MatchString(s) {
+			return s
+		}
+		matched = true
+		return pattern.ReplaceAllLiteralString(s, replacement)
+	}
+
+	svc.Command.Line = apply(svc.Command.Line)
+	for i, arg := range svc.Command.Args {
+		svc.Command.Args[i] = apply(arg)
+	}
+	for key, value := range svc.Env {
+		svc.Env[key] = apply(value)
+	}
+	if svc.Hooks != nil {
+		for i, command := range svc.Hooks.PostStart {
+			svc.Hooks.PostStart[i] = apply(command)
+		}
+	}
+	return matched
+}
+
+// commandFromLine returns a command for a shell command line, running it
+// through sh when it uses syntax direct exec can't handle
+func commandFromLine(line string) (config.Command, string) {
+	lookup := func(string) (string, bool) { return "", true }
+	if _, _, err := process.SplitCommand(line, lookup); err != nil {
+		return config.NewCommand(line), "sh"
+	}
+	return config.NewCommand(line), ""
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
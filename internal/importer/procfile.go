@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+)
+
+// procfileLine matches a "name: command" process entry
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.*)$`)
+
+// ReadProcfile reads the processes of a Procfile. Each process gets the
+// allocated port as PORT, as on Heroku and with foreman.
+func ReadProcfile(path string) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+	defer file.Close()
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		match := procfileLine.FindStringSubmatch(line)
+		if match == nil {
+			result.warnf("line %d: not a process entry: %s", lineNum, line)
+			continue
+		}
+		name, line := match[1], match[2]
+		if result.Process(name) != nil {
+			result.warnf("line %d: duplicate process %s ignored", lineNum, name)
+			continue
+		}
+		if line == "" {
+			result.warnf("line %d: process %s has no command", lineNum, name)
+			continue
+		}
+
+		command, shell := commandFromLine(line)
+		result.Processes = append(result.Processes, &Process{
+			Name: name,
+			Service: &config.Service{
+				Directory: dir,
+				Command:   command,
+				Shell:     shell,
+				Env:       map[string]string{"PORT": "${port}"},
+			},
+			Dir:      dir,
+			Runnable: true,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+
+	if len(result.Processes) == 0 {
+		return nil, fmt.Errorf("no processes found in %s", path)
+	}
+	return result, nil
+}
@@ -16,23 +16,66 @@ const (
 	FrontendPortStart = 5000
 	// FrontendPortEnd is the ending port for frontend services
 	FrontendPortEnd = 5999
+	// SidecarPortStart is the starting port for sidecars
+	SidecarPortStart = 7000
+	// SidecarPortEnd is the ending port for sidecars
+	SidecarPortEnd = 7999
 )
 
 // Allocator manages port allocation
 type Allocator struct {
-	state *config.State
+	state    *config.State
+	backend  config.PortRange
+	frontend config.PortRange
+	sidecars config.PortRange
+	// others holds the ports reserved by other profiles, and those handed
+	// out with Reserve
+	others map[int]bool
 }
 
-// NewAllocator creates a new port allocator
-func NewAllocator(state *config.State) *Allocator {
-	return &Allocator{state: state}
+// NewAllocator creates a new port allocator. ranges may be nil, and unset
+// ranges fall back to the defaults. Ports reserved by other profiles are
+// never allocated.
+func NewAllocator(state *config.State, ranges *config.PortsConfig) *Allocator {
+	a := &Allocator{
+		state:    state,
+		backend:  config.PortRange{Start: BackendPortStart, End: BackendPortEnd},
+		frontend: config.PortRange{Start: FrontendPortStart, End: FrontendPortEnd},
+		sidecars: config.PortRange{Start: SidecarPortStart, End: SidecarPortEnd},
+		others:   config.OtherProfilePorts(),
+	}
+	if ranges != nil && !ranges.Backend.IsZero() {
+		a.backend = ranges.Backend
+	}
+	if ranges != nil && !ranges.Frontend.IsZero() {
+		a.frontend = ranges.Frontend
+	}
+	if ranges != nil && !ranges.Sidecars.IsZero() {
+		a.sidecars = ranges.Sidecars
+	}
+	return a
+}
+
+// Reserve keeps a port from being allocated again by this allocator, for
+// ports handed out before they are recorded in state
+func (a *Allocator) Reserve(port int) {
+	a.others[port] = true
 }
 
 // AllocateBackendPort finds and allocates an available backend port
 func (a *Allocator) AllocateBackendPort() (int, error) {
+	return a.AllocateBackendPortPreferring(0)
+}
+
+// AllocateBackendPortPreferring allocates preferred if it is still free,
+// falling back to any available backend port
+func (a *Allocator) AllocateBackendPortPreferring(preferred int) (int, error) {
 	usedPorts := a.getUsedBackendPorts()
+	if preferred > 0 && !usedPorts[preferred] && isPortAvailable(preferred) {
+		return preferred, nil
+	}
 
-	for port := BackendPortStart; port <= BackendPortEnd; port++ {
+	for port := a.backend.Start; port <= a.backend.End; port++ {
 		if usedPorts[port] {
 			continue
 		}
@@ -42,14 +85,23 @@ func (a *Allocator) AllocateBackendPort() (int, error) {
 		}
 	}
 
-	return 0, fmt.Errorf("no available backend ports in range %d-%d", BackendPortStart, BackendPortEnd)
+	return 0, fmt.Errorf("no available backend ports in range %s", a.backend)
 }
 
 // AllocateFrontendPort finds and allocates an available frontend port
 func (a *Allocator) AllocateFrontendPort() (int, error) {
+	return a.AllocateFrontendPortPreferring(0)
+}
+
+// AllocateFrontendPortPreferring allocates preferred if it is still free,
+// falling back to any available frontend port
+func (a *Allocator) AllocateFrontendPortPreferring(preferred int) (int, error) {
 	usedPorts := a.getUsedFrontendPorts()
+	if preferred > 0 && !usedPorts[preferred] && isPortAvailable(preferred) {
+		return preferred, nil
+	}
 
-	for port := FrontendPortStart; port <= FrontendPortEnd; port++ {
+	for port := a.frontend.Start; port <= a.frontend.End; port++ {
 		if usedPorts[port] {
 			continue
 		}
@@ -59,14 +111,39 @@ func (a *Allocator) AllocateFrontendPort() (int, error) {
 		}
 	}
 
-	return 0, fmt.Errorf("no available frontend ports in range %d-%d", FrontendPortStart, FrontendPortEnd)
+	return 0, fmt.Errorf("no available frontend ports in range %s", a.frontend)
 }
 
-// getUsedBackendPorts returns a map of currently allocated backend ports
+// AllocateSidecarPortPreferring allocates preferred if it is still free,
+// falling back to any available sidecar port
+func (a *Allocator) AllocateSidecarPortPreferring(preferred int) (int, error) {
+	usedPorts := a.getUsedSidecarPorts()
+	if preferred > 0 && !usedPorts[preferred] && isPortAvailable(preferred) {
+		return preferred, nil
+	}
+
+	for port := a.sidecars.Start; port <= a.sidecars.End; port++ {
+		if usedPorts[port] {
+			continue
+		}
+
+		if isPortAvailable(port) {
+			return port, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no available sidecar ports in range %s", a.sidecars)
+}
+
+// getUsedBackendPorts returns a map of currently allocated backend ports,
+// along with those of other profiles
 func (a *Allocator) getUsedBackendPorts() map[int]bool {
 	used := make(map[int]bool)
+	for port := range a.others {
+		used[port] = true
+	}
 
-	for _, groupState := range a.state.Groups {
+	for _, groupState := range a.state.GroupStates() {
 		if groupState.BackendPort > 0 {
 			used[groupState.BackendPort] = true
 		}
@@ -75,11 +152,15 @@ func (a *Allocator) getUsedBackendPorts() map[int]bool {
 	return used
 }
 
-// getUsedFrontendPorts returns a map of currently allocated frontend ports
+// getUsedFrontendPorts returns a map of currently allocated frontend ports,
+// along with those of other profiles
 func (a *Allocator) getUsedFrontendPorts() map[int]bool {
 	used := make(map[int]bool)
+	for port := range a.others {
+		used[port] = true
+	}
 
-	for _, groupState := range a.state.Groups {
+	for _, groupState := range a.state.GroupStates() {
 		if groupState.FrontendPort > 0 {
 			used[groupState.FrontendPort] = true
 		}
@@ -88,6 +169,26 @@ func (a *Allocator) getUsedFrontendPorts() map[int]bool {
 	return used
 }
 
+// getUsedSidecarPorts returns a map of currently allocated sidecar ports,
+// including shared ones, along with those of other profiles
+func (a *Allocator) getUsedSidecarPorts() map[int]bool {
+	used := make(map[int]bool)
+	for port := range a.others {
+		used[port] = true
+	}
+
+	for _, groupState := range a.state.GroupStates() {
+		for _, sidecar := range groupState.Sidecars {
+			used[sidecar.Port] = true
+		}
+	}
+	for _, sidecar := range a.state.SharedSidecars() {
+		used[sidecar.Port] = true
+	}
+
+	return used
+}
+
 // isPortAvailable checks if a port is available for binding
 func isPortAvailable(port int) bool {
 	addr := fmt.Sprintf("localhost:%d", port)
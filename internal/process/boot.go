@@ -0,0 +1,44 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	bootOnce sync.Once
+	bootID   string
+	bootErr  error
+)
+
+// BootID returns an identifier of the current system boot, which changes
+// whenever the machine restarts
+func BootID() (string, error) {
+	bootOnce.Do(func() {
+		bootID, bootErr = readBootID()
+	})
+	return bootID, bootErr
+}
+
+func readBootID() (string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+		if err != nil {
+			return "", fmt.Errorf("failed to read boot ID: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case "darwin", "freebsd", "netbsd", "openbsd":
+		// The boot time identifies the boot, e.g. "{ sec = 1700000000, usec = 0 } ..."
+		out, err := exec.Command("sysctl", "-n", "kern.boottime").Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to read boot time: %w", err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", fmt.Errorf("boot detection is not supported on %s", runtime.GOOS)
+}
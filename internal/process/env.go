@@ -0,0 +1,161 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kris-hansen/grappler/internal/config"
+)
+
+// Env sources, from lowest to highest precedence
+const (
+	SourceHost     = "host"
+	SourceConfig   = "config env"
+	SourceOverride = "run override"
+	SourceGrappler = "grappler"
+)
+
+// EnvVar is an environment variable and the source that set it
+type EnvVar struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Env is an environment built up in layers, where each Set overrides any
+// earlier value and the overridden values are kept for explanation
+type Env struct {
+	vars    map[string]EnvVar
+	history map[string][]EnvVar
+}
+
+// NewEnv creates an empty environment
+func NewEnv() *Env {
+	return &Env{
+		vars:    make(map[string]EnvVar),
+		history: make(map[string][]EnvVar),
+	}
+}
+
+// Set sets a variable, recording the source that set it
+func (e *Env) Set(key, value, source string) {
+	if previous, ok := e.vars[key]; ok {
+		e.history[key] = append(e.history[key], previous)
+	}
+	e.vars[key] = EnvVar{Key: key, Value: value, Source: source}
+}
+
+// SetAll sets every variable in vars from the same source
+func (e *Env) SetAll(vars map[string]string, source string) {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		e.Set(key, vars[key], source)
+	}
+}
+
+// Lookup returns the current value of a variable
+func (e *Env) Lookup(key string) (string, bool) {
+	v, ok := e.vars[key]
+	return v.Value, ok
+}
+
+// Vars returns all variables sorted by key
+func (e *Env) Vars() []EnvVar {
+	vars := make([]EnvVar, 0, len(e.vars))
+	for _, v := range e.vars {
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool {
+		return vars[i].Key < vars[j].Key
+	})
+	return vars
+}
+
+// Overridden returns the earlier values of a variable, oldest first
+func (e *Env) Overridden(key string) []EnvVar {
+	return e.history[key]
+}
+
+// List returns the environment as KEY=value entries for exec
+func (e *Env) List() []string {
+	vars := e.Vars()
+	env := make([]string, 0, len(vars))
+	for _, v := range vars {
+		env = append(env, v.Key+"="+v.Value)
+	}
+	return env
+}
+
+// BuildEnv returns the environment for a service of a group (or for the
+// group itself when service is nil), layered from lowest to highest
+// precedence:
+//
+//  1. the host environment
+//  2. env files: the group's, then the service's, in the order listed
+//  3. env from the service config
+//  4. per-run overrides given with 'grappler start --env'
+//  5. env vars injected by grappler (ports, discovery variables)
+//
+// Relative env file paths are resolved against the service directory.
+func BuildEnv(group *config.Group, service *config.Service, overrides, envVars map[string]string) (*Env, error) {
+	env := NewEnv()
+
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env.Set(key, value, SourceHost)
+		}
+	}
+
+	dir := ""
+	var files []config.EnvFile
+	if group != nil {
+		dir = group.Directory()
+		files = append(files, group.EnvFiles...)
+	}
+	if service != nil {
+		dir = service.Directory
+		files = append(files, service.EnvFiles...)
+	}
+
+	for _, file := range files {
+		path := file.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		entries, err := config.ReadDotenv(path, env.Lookup)
+		if err != nil {
+			if os.IsNotExist(err) && file.Optional {
+				continue
+			}
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("env file %s not found (mark it optional: true to skip)", path)
+			}
+			return nil, fmt.Errorf("failed to load env file: %w", err)
+		}
+
+		for _, entry := range entries {
+			env.Set(entry.Key, entry.Value, file.Path)
+		}
+	}
+
+	// Add service-specific env vars from config
+	if service != nil {
+		env.SetAll(service.Env, SourceConfig)
+	}
+
+	env.SetAll(overrides, SourceOverride)
+
+	// Add runtime env vars (ports)
+	env.SetAll(envVars, SourceGrappler)
+
+	return env, nil
+}
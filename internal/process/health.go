@@ -2,7 +2,9 @@ package process
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -22,7 +24,16 @@ func NewHealthChecker() *HealthChecker {
 
 // WaitForHealth waits for a service to become healthy
 func (h *HealthChecker) WaitForHealth(port int, maxWait time.Duration) error {
-	url := fmt.Sprintf("http://localhost:%d", port)
+	return h.WaitForPath(port, "/", maxWait)
+}
+
+// WaitForPath waits for a service to answer requests for path with a
+// status below 500
+func (h *HealthChecker) WaitForPath(port int, path string, maxWait time.Duration) error {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("http://localhost:%d%s", port, path)
 	deadline := time.Now().Add(maxWait)
 
 	for time.Now().Before(deadline) {
@@ -39,3 +50,25 @@ func (h *HealthChecker) WaitForHealth(port int, maxWait time.Duration) error {
 
 	return fmt.Errorf("service did not become healthy within %s", maxWait)
 }
+
+// WaitForPort waits for something to accept TCP connections on port, giving
+// up early once exited reports that the process meant to listen is gone
+func (h *HealthChecker) WaitForPort(port int, maxWait time.Duration, exited func() bool) error {
+	addr := fmt.Sprintf("localhost:%d", port)
+	deadline := time.Now().Add(maxWait)
+
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if exited != nil && exited() {
+			return fmt.Errorf("process exited before listening on port %d", port)
+		}
+
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	return fmt.Errorf("nothing listening on port %d after %s", port, maxWait)
+}
@@ -0,0 +1,65 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// HookError is returned when a hook command exits unsuccessfully
+type HookError struct {
+	Stage    string
+	Command  string
+	ExitCode int
+	Err      error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook %q failed (exit code %d): %v", e.Stage, e.Command, e.ExitCode, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// RunHooks runs hook commands sequentially through shell in dir, appending
+// their output to the log for logName. It stops at the first failing command.
+func (m *Manager) RunHooks(stage string, commands []string, dir, shell, groupName, logName string, env []string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.logsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create logs directory: %w", err)
+	}
+
+	logFile, err := m.openLog(groupName, logName)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	for _, command := range commands {
+		fmt.Fprintf(logFile, "==> [%s %s] %s\n", time.Now().Format(time.RFC3339), stage, command)
+
+		cmd := exec.Command(shell, "-c", command)
+		cmd.Dir = dir
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		cmd.Env = env
+
+		if err := cmd.Run(); err != nil {
+			exitCode := 1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+				exitCode = exitErr.ExitCode()
+			}
+			fmt.Fprintf(logFile, "==> [%s] exited with code %d\n", stage, exitCode)
+			return &HookError{Stage: stage, Command: command, ExitCode: exitCode, Err: err}
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package process
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -22,67 +23,172 @@ func NewManager(logsDir string) *Manager {
 	}
 }
 
-// StartService starts a service and returns its PID
-func (m *Manager) StartService(service *config.Service, serviceName, groupName string, envVars map[string]string) (int, error) {
+// StartService starts a service with the given environment (see BuildEnv)
+// and returns its PID and the argv it executed
+func (m *Manager) StartService(service *config.Service, serviceName, groupName string, env []string) (int, []string, error) {
+	return m.start(service, serviceName, groupName, env, false)
+}
+
+// StartDetached starts a service like StartService, but in a process group
+// of its own, so signals sent to grappler's terminal (such as Ctrl-C under
+// up) don't reach it. Sidecars that outlive the group starting them run
+// this way.
+func (m *Manager) StartDetached(service *config.Service, serviceName, groupName string, env []string) (int, []string, error) {
+	return m.start(service, serviceName, groupName, env, true)
+}
+
+func (m *Manager) start(service *config.Service, serviceName, groupName string, env []string, ownGroup bool) (int, []string, error) {
 	if service == nil {
-		return 0, nil
+		return 0, nil, nil
 	}
 
-	// Create logs directory if it doesn't exist
-	if err := os.MkdirAll(m.logsDir, 0755); err != nil {
-		return 0, fmt.Errorf("failed to create logs directory: %w", err)
+	cmd, argv, logFile, err := m.launch(service, serviceName, groupName, env, nil, ownGroup)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	// Open log file
-	logPath := filepath.Join(m.logsDir, fmt.Sprintf("%s-%s.log", groupName, serviceName))
-	logFile, err := os.Create(logPath)
+	// Launch goroutine to wait for process and close log file
+	go func() {
+		cmd.Wait()
+		logFile.Close()
+	}()
+
+	return cmd.Process.Pid, argv, nil
+}
+
+// RunService starts a service attached to grappler, writing its output to
+// out as well as its log. The service runs in its own process group so it
+// only stops when grappler says so (see SignalGroup). It returns the PID, the
+// argv it executed and a channel receiving the result of waiting for it.
+func (m *Manager) RunService(service *config.Service, serviceName, groupName string, env []string, out io.Writer) (int, []string, <-chan error, error) {
+	cmd, argv, logFile, err := m.launch(service, serviceName, groupName, env, out, true)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+		logFile.Close()
+	}()
+
+	return cmd.Process.Pid, argv, done, nil
+}
+
+// launch resolves and starts a service command with output to its log, and
+// also to out when set. With ownGroup it gets a process group of its own.
+func (m *Manager) launch(service *config.Service, serviceName, groupName string, env []string, out io.Writer, ownGroup bool) (*exec.Cmd, []string, *os.File, error) {
+	// Resolve the command before touching the log so parse errors fail fast
+	argv, env, err := ResolveCommand(service, env)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create log file: %w", err)
+		return nil, nil, nil, err
 	}
 
-	// Parse command
-	cmdParts := parseCommand(service.Command)
-	if len(cmdParts) == 0 {
-		return 0, fmt.Errorf("empty command")
+	// Create logs directory if it doesn't exist
+	if err := os.MkdirAll(m.logsDir, 0755); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create logs directory: %w", err)
+	}
+
+	// Open log file (appending, so hook output written before start is kept)
+	logFile, err := m.openLog(groupName, serviceName)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	// Create command
-	cmd := exec.Command(cmdParts[0], cmdParts[1:]...)
+	cmd := exec.Command(argv[0], argv[1:]...)
 	cmd.Dir = service.Directory
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
+	cmd.Env = env
 
-	// Set environment variables
-	cmd.Env = os.Environ()
-
-	// Add service-specific env vars from config
-	if service.Env != nil {
-		for key, value := range service.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
-		}
+	if out != nil {
+		cmd.Stdout = io.MultiWriter(logFile, out)
+		cmd.Stderr = cmd.Stdout
 	}
-
-	// Add runtime env vars (ports)
-	for key, value := range envVars {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	if ownGroup {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	}
 
 	// Start the process
 	if err := cmd.Start(); err != nil {
 		logFile.Close()
-		return 0, fmt.Errorf("failed to start process: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to start process: %w", err)
 	}
 
-	// Return PID
-	pid := cmd.Process.Pid
+	return cmd, argv, logFile, nil
+}
 
-	// Launch goroutine to wait for process and close log file
-	go func() {
-		cmd.Wait()
-		logFile.Close()
-	}()
+// ResolveCommand returns the argv and environment a service command runs with.
+// With a shell configured the command runs as `<shell> -c <line>`; list
+// commands run as-is; command lines are split with SplitCommand, expanding
+// variables from env and adding any leading assignments to it.
+func ResolveCommand(service *config.Service, env []string) ([]string, []string, error) {
+	command := service.Command
+	if command.IsZero() {
+		return nil, nil, fmt.Errorf("no command set (add one to the worktree's %s or the grappler config)", config.RepoConfigFile)
+	}
+
+	if service.Shell != "" {
+		line := command.Line
+		if len(command.Args) > 0 {
+			line = QuoteArgv(command.Args)
+		}
+		return []string{service.Shell, "-c", line}, env, nil
+	}
 
-	return pid, nil
+	if len(command.Args) > 0 {
+		return append([]string(nil), command.Args...), env, nil
+	}
+
+	assignments, argv, err := SplitCommand(command.Line, envLookup(env))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse command %q: %w", command.Line, err)
+	}
+	if len(argv) == 0 {
+		return nil, nil, fmt.Errorf("empty command")
+	}
+
+	for key, value := range assignments {
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	return argv, env, nil
+}
+
+// HookShell returns the shell that a service's hooks run through
+func HookShell(service *config.Service) string {
+	if service != nil && service.Shell != "" {
+		return service.Shell
+	}
+	return "sh"
+}
+
+// LogPath returns the log file path for a service of a group
+func (m *Manager) LogPath(groupName, serviceName string) string {
+	return filepath.Join(m.logsDir, fmt.Sprintf("%s-%s.log", groupName, serviceName))
+}
+
+// ResetLog truncates the log file for a service, creating it if needed
+func (m *Manager) ResetLog(groupName, serviceName string) error {
+	if err := os.MkdirAll(m.logsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create logs directory: %w", err)
+	}
+
+	logFile, err := os.Create(m.LogPath(groupName, serviceName))
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	return logFile.Close()
+}
+
+// openLog opens the log file for a service in append mode
+func (m *Manager) openLog(groupName, serviceName string) (*os.File, error) {
+	logFile, err := os.OpenFile(m.LogPath(groupName, serviceName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return logFile, nil
 }
 
 // StopProcess stops a process by sending SIGTERM
@@ -104,6 +210,22 @@ func (m *Manager) StopProcess(pid int) error {
 	return nil
 }
 
+// SignalGroup sends a signal to the process group led by pid, falling back
+// to the process itself if it has no group of its own
+func (m *Manager) SignalGroup(pid int, sig syscall.Signal) error {
+	if pid == 0 {
+		return nil
+	}
+
+	if err := syscall.Kill(-pid, sig); err == nil {
+		return nil
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("failed to signal process: %w", err)
+	}
+	return nil
+}
+
 // IsProcessRunning checks if a process is running
 func (m *Manager) IsProcessRunning(pid int) bool {
 	if pid == 0 {
@@ -119,30 +241,3 @@ func (m *Manager) IsProcessRunning(pid int) bool {
 	err = process.Signal(syscall.Signal(0))
 	return err == nil
 }
-
-// parseCommand splits a command string into parts
-func parseCommand(cmd string) []string {
-	// Simple split on spaces - could be enhanced for quoted strings
-	parts := []string{}
-	current := ""
-	inQuote := false
-
-	for _, char := range cmd {
-		if char == '"' {
-			inQuote = !inQuote
-		} else if char == ' ' && !inQuote {
-			if current != "" {
-				parts = append(parts, current)
-				current = ""
-			}
-		} else {
-			current += string(char)
-		}
-	}
-
-	if current != "" {
-		parts = append(parts, current)
-	}
-
-	return parts
-}
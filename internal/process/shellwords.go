@@ -0,0 +1,294 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SplitCommand parses a command line into argv using POSIX shell word rules:
+// single and double quotes, backslash escapes, $VAR / ${VAR} / ${VAR:-default}
+// expansion and leading NAME=value assignments, which are returned separately.
+// Variables are resolved with lookup. Shell operators such as pipes, redirects,
+// && and command substitution are rejected; run those through a shell instead.
+func SplitCommand(line string, lookup func(string) (string, bool)) (map[string]string, []string, error) {
+	p := &wordParser{input: []rune(line), lookup: lookup}
+	words, err := p.parse()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Leading NAME=value words are environment assignments
+	assignments := make(map[string]string)
+	for len(words) > 0 && words[0].assignment {
+		name, value, _ := strings.Cut(words[0].text, "=")
+		assignments[name] = value
+		words = words[1:]
+	}
+
+	argv := make([]string, 0, len(words))
+	for _, word := range words {
+		argv = append(argv, word.text)
+	}
+
+	return assignments, argv, nil
+}
+
+// QuoteArgv joins argv into a single line, quoting arguments so the result
+// can be pasted into a POSIX shell
+func QuoteArgv(argv []string) string {
+	quoted := make([]string, 0, len(argv))
+	for _, arg := range argv {
+		quoted = append(quoted, quoteWord(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quoteWord single-quotes a word if it contains shell metacharacters
+func quoteWord(word string) string {
+	if word == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(word, " \t\n'\"\\$`|&;<>()*?[]#~{}!") {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// envLookup returns a lookup function over a KEY=value environment list,
+// where later entries win
+func envLookup(env []string) func(string) (string, bool) {
+	values := make(map[string]string, len(env))
+	for _, entry := range env {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			values[key] = value
+		}
+	}
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+type shellWord struct {
+	text       string
+	assignment bool
+}
+
+type wordParser struct {
+	input  []rune
+	pos    int
+	lookup func(string) (string, bool)
+}
+
+func (p *wordParser) parse() ([]shellWord, error) {
+	var words []shellWord
+
+	for {
+		// Skip whitespace and line continuations between words
+		for p.pos < len(p.input) {
+			if isBlank(p.input[p.pos]) {
+				p.pos++
+			} else if p.input[p.pos] == '\\' && p.pos+1 < len(p.input) && p.input[p.pos+1] == '\n' {
+				p.pos += 2
+			} else {
+				break
+			}
+		}
+		if p.pos >= len(p.input) {
+			return words, nil
+		}
+
+		// A word starting with # begins a comment
+		if p.input[p.pos] == '#' {
+			return words, nil
+		}
+
+		word, err := p.parseWord(len(words) == 0 || words[len(words)-1].assignment)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+}
+
+// parseWord reads one word; assignments are only recognised in command
+// prefix position
+func (p *wordParser) parseWord(prefix bool) (shellWord, error) {
+	var b strings.Builder
+	start := p.pos
+	quotedName := false
+
+	// Tilde expansion at the start of an unquoted word
+	if p.input[p.pos] == '~' && (p.pos+1 == len(p.input) || p.input[p.pos+1] == '/' || isBlank(p.input[p.pos+1])) {
+		home, _ := os.UserHomeDir()
+		b.WriteString(home)
+		p.pos++
+	}
+
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+
+		switch {
+		case isBlank(c):
+			return p.finishWord(b.String(), prefix && !quotedName), nil
+		case c == '\'':
+			quotedName = quotedName || !strings.Contains(string(p.input[start:p.pos]), "=")
+			end := indexRune(p.input, p.pos+1, '\'')
+			if end < 0 {
+				return shellWord{}, fmt.Errorf("unterminated single quote in command")
+			}
+			b.WriteString(string(p.input[p.pos+1 : end]))
+			p.pos = end + 1
+		case c == '"':
+			quotedName = quotedName || !strings.Contains(string(p.input[start:p.pos]), "=")
+			if err := p.parseDoubleQuoted(&b); err != nil {
+				return shellWord{}, err
+			}
+		case c == '\\':
+			p.pos++
+			if p.pos >= len(p.input) {
+				return shellWord{}, fmt.Errorf("command ends with a dangling backslash")
+			}
+			if p.input[p.pos] != '\n' {
+				b.WriteRune(p.input[p.pos])
+			}
+			p.pos++
+		case c == '$':
+			if err := p.expand(&b); err != nil {
+				return shellWord{}, err
+			}
+		case strings.ContainsRune("|&;<>()`", c):
+			op := string(c)
+			if p.pos+1 < len(p.input) && p.input[p.pos+1] == c && strings.ContainsRune("|&<>", c) {
+				op += string(c)
+			}
+			return shellWord{}, shellSyntaxError(op)
+		default:
+			b.WriteRune(c)
+			p.pos++
+		}
+	}
+
+	return p.finishWord(b.String(), prefix && !quotedName), nil
+}
+
+func (p *wordParser) finishWord(text string, prefix bool) shellWord {
+	name, _, ok := strings.Cut(text, "=")
+	return shellWord{text: text, assignment: prefix && ok && isName(name)}
+}
+
+// parseDoubleQuoted reads a double-quoted section, where only $, `, ", \
+// and newline can be escaped and variables are expanded
+func (p *wordParser) parseDoubleQuoted(b *strings.Builder) error {
+	p.pos++
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return nil
+		case '\\':
+			if p.pos+1 < len(p.input) && strings.ContainsRune("$`\"\\\n", p.input[p.pos+1]) {
+				if p.input[p.pos+1] != '\n' {
+					b.WriteRune(p.input[p.pos+1])
+				}
+				p.pos += 2
+				continue
+			}
+			b.WriteRune(c)
+			p.pos++
+		case '$':
+			if err := p.expand(b); err != nil {
+				return err
+			}
+		case '`':
+			return shellSyntaxError("`")
+		default:
+			b.WriteRune(c)
+			p.pos++
+		}
+	}
+	return fmt.Errorf("unterminated double quote in command")
+}
+
+// expand expands a $VAR, ${VAR} or ${VAR:-default} reference at p.pos
+func (p *wordParser) expand(b *strings.Builder) error {
+	p.pos++
+	if p.pos >= len(p.input) {
+		b.WriteRune('$')
+		return nil
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c == '{':
+		end := indexRune(p.input, p.pos+1, '}')
+		if end < 0 {
+			return fmt.Errorf("unterminated ${ in command")
+		}
+		expr := string(p.input[p.pos+1 : end])
+		p.pos = end + 1
+
+		name, fallback, hasDefault := strings.Cut(expr, ":-")
+		if !isName(name) {
+			return fmt.Errorf("unsupported variable expansion ${%s} in command", expr)
+		}
+		value, ok := p.lookup(name)
+		if (!ok || value == "") && hasDefault {
+			value = fallback
+		}
+		b.WriteString(value)
+	case c == '(':
+		return shellSyntaxError("$(")
+	case isNameStart(c):
+		start := p.pos
+		for p.pos < len(p.input) && isNameChar(p.input[p.pos]) {
+			p.pos++
+		}
+		value, _ := p.lookup(string(p.input[start:p.pos]))
+		b.WriteString(value)
+	default:
+		// A lone $ is literal
+		b.WriteRune('$')
+	}
+	return nil
+}
+
+// shellSyntaxError reports shell syntax that direct exec cannot run
+func shellSyntaxError(op string) error {
+	return fmt.Errorf("command uses shell syntax %q; set \"shell: sh\" on the service to run it through a shell", op)
+}
+
+func indexRune(input []rune, from int, r rune) int {
+	for i := from; i < len(input); i++ {
+		if input[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func isBlank(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isNameStart(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c rune) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if i == 0 && !isNameStart(c) || !isNameChar(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package process
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	env := map[string]string{"FOO": "bar", "EMPTY": "", "SPACED": "a b"}
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	tests := []struct {
+		name        string
+		line        string
+		argv        []string
+		assignments map[string]string
+	}{
+		{name: "plain words", line: "go run  ./cmd/api\t-v", argv: []string{"go", "run", "./cmd/api", "-v"}},
+		{name: "empty line", line: "   ", argv: []string{}},
+		{name: "double quotes in single quotes", line: `sh -c 'echo "hi there"'`, argv: []string{"sh", "-c", `echo "hi there"`}},
+		{name: "single quotes in double quotes", line: `echo "it's" 'say "x"'`, argv: []string{"echo", "it's", `say "x"`}},
+		{name: "quoted parts join", line: `a"b c"'d e'f`, argv: []string{"ab cd ef"}},
+		{name: "escapes in double quotes", line: `echo "a \"b\" \$FOO \\ \x"`, argv: []string{"echo", `a "b" $FOO \ \x`}},
+		{name: "single quotes are literal", line: `echo '$FOO \n'`, argv: []string{"echo", `$FOO \n`}},
+		{name: "escaped blank", line: `touch a\ b c\"d`, argv: []string{"touch", "a b", `c"d`}},
+		{name: "backslash-newline joins", line: "echo a\\\nb", argv: []string{"echo", "ab"}},
+		{name: "backslash-newline between words", line: "echo a \\\n  b", argv: []string{"echo", "a", "b"}},
+		{name: "backslash-newline at the end", line: "echo a \\\n", argv: []string{"echo", "a"}},
+		{name: "backslash-newline in double quotes", line: "echo \"a\\\nb\"", argv: []string{"echo", "ab"}},
+		{name: "empty arguments", line: `printf "" ''`, argv: []string{"printf", "", ""}},
+		{name: "variables", line: `echo $FOO ${FOO}x "$SPACED"`, argv: []string{"echo", "bar", "barx", "a b"}},
+		{name: "defaults", line: `echo ${MISSING:-dflt} ${EMPTY:-d2} ${FOO:-unused}`, argv: []string{"echo", "dflt", "d2", "bar"}},
+		{name: "lone dollar", line: `echo $ a$ $1x`, argv: []string{"echo", "$", "a$", "$1x"}},
+		{name: "comment", line: "echo hi # the rest", argv: []string{"echo", "hi"}},
+		{name: "hash inside a word", line: "echo a#b", argv: []string{"echo", "a#b"}},
+		{name: "tilde", line: "ls ~/src ~ a~", argv: []string{"ls", home + "/src", home, "a~"}},
+		{
+			name:        "leading assignments",
+			line:        `FOO=1 BAR="a b" EMPTY= cmd X=2`,
+			argv:        []string{"cmd", "X=2"},
+			assignments: map[string]string{"FOO": "1", "BAR": "a b", "EMPTY": ""},
+		},
+		{name: "assignment only", line: "FOO=1", argv: []string{}, assignments: map[string]string{"FOO": "1"}},
+		{name: "quoted name is no assignment", line: `"FOO"=1 cmd`, argv: []string{"FOO=1", "cmd"}},
+		{name: "invalid name is no assignment", line: "1X=2 cmd", argv: []string{"1X=2", "cmd"}},
+		{name: "assignment expands", line: "URL=http://$FOO:${PORT:-80} cmd", argv: []string{"cmd"}, assignments: map[string]string{"URL": "http://bar:80"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignments, argv, err := SplitCommand(tt.line, lookup)
+			if err != nil {
+				t.Fatalf("SplitCommand(%q) error = %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(argv, tt.argv) {
+				t.Errorf("SplitCommand(%q) argv = %q, want %q", tt.line, argv, tt.argv)
+			}
+			if tt.assignments == nil {
+				tt.assignments = map[string]string{}
+			}
+			if !reflect.DeepEqual(assignments, tt.assignments) {
+				t.Errorf("SplitCommand(%q) assignments = %q, want %q", tt.line, assignments, tt.assignments)
+			}
+		})
+	}
+}
+
+func TestSplitCommandErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: `echo 'abc`, want: "unterminated single quote"},
+		{line: `echo "abc`, want: "unterminated double quote"},
+		{line: `echo "abc\"`, want: "unterminated double quote"},
+		{line: `echo abc\`, want: "dangling backslash"},
+		{line: `echo ${FOO`, want: "unterminated ${"},
+		{line: `echo ${FOO/a/b}`, want: "unsupported variable expansion"},
+		{line: `a | b`, want: `"|"`},
+		{line: `a || b`, want: `"||"`},
+		{line: `a & b`, want: `"&"`},
+		{line: `a && b`, want: `"&&"`},
+		{line: `a; b`, want: `";"`},
+		{line: `a < in`, want: `"<"`},
+		{line: `a << EOF`, want: `"<<"`},
+		{line: `a > out`, want: `">"`},
+		{line: `a >> out`, want: `">>"`},
+		{line: `a 2>&1`, want: `">"`},
+		{line: `(a)`, want: `"("`},
+		{line: `a)`, want: `")"`},
+		{line: "echo `date`", want: "\"`\""},
+		{line: "echo \"`date`\"", want: "\"`\""},
+		{line: `echo $(date)`, want: `"$("`},
+		{line: `echo "$(date)"`, want: `"$("`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, _, err := SplitCommand(tt.line, func(string) (string, bool) { return "", false })
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("SplitCommand(%q) error = %v, want one containing %s", tt.line, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteArgv(t *testing.T) {
+	argv := []string{"echo", "", "a b", "it's", "$HOME", "plain-word"}
+	line := QuoteArgv(argv)
+	if want := `echo '' 'a b' 'it'\''s' '$HOME' plain-word`; line != want {
+		t.Errorf("QuoteArgv() = %s, want %s", line, want)
+	}
+
+	// Quoted argv parses back to itself
+	_, parsed, err := SplitCommand(line, func(string) (string, bool) { return "", false })
+	if err != nil || !reflect.DeepEqual(parsed, argv) {
+		t.Errorf("SplitCommand(QuoteArgv()) = %q, %v; want %q", parsed, err, argv)
+	}
+}
@@ -0,0 +1,81 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// UserUnitDir returns the directory systemd user units are installed in
+func UserUnitDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".config", "systemd", "user")
+	}
+	return filepath.Join(home, ".config", "systemd", "user")
+}
+
+// InstallUnit writes a unit file to the user unit directory and returns its path
+func InstallUnit(name, content string) (string, error) {
+	dir := UserUnitDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create unit directory: %w", err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write unit %s: %w", name, err)
+	}
+	return path, nil
+}
+
+// RemoveUnit deletes a unit file from the user unit directory, ignoring
+// units that don't exist
+func RemoveUnit(name string) error {
+	if err := os.Remove(filepath.Join(UserUnitDir(), name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove unit %s: %w", name, err)
+	}
+	return nil
+}
+
+// Systemctl runs systemctl for the user's service manager
+func Systemctl(args ...string) error {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return fmt.Errorf("systemctl not found: systemd is required")
+	}
+
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl --user %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// QuoteArg quotes a command argument for ExecStart= and similar directives,
+// escaping systemd's variable and specifier expansion
+func QuoteArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	arg = strings.ReplaceAll(arg, "$", "$$")
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\;") {
+		return arg
+	}
+	return quote(arg)
+}
+
+// QuoteEnv quotes a KEY=value assignment for Environment=
+func QuoteEnv(key, value string) string {
+	return quote(key + "=" + strings.ReplaceAll(value, "%", "%%"))
+}
+
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
@@ -0,0 +1,135 @@
+package systemd
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Unit is a generated unit file
+type Unit struct {
+	Name    string
+	Content string
+}
+
+// ServiceSpec describes a service of a group to generate a unit for
+type ServiceSpec struct {
+	Name    string
+	Dir     string
+	Argv    []string
+	Env     map[string]string
+	LogPath string
+	// After lists the services of the group that must start first
+	After []string
+	// Hook commands, each run as an argv
+	ExecStartPre  [][]string
+	ExecStartPost [][]string
+	ExecStop      [][]string
+	ExecStopPost  [][]string
+}
+
+// TargetName returns the name of the target unit of a group
+func TargetName(group string) string {
+	return fmt.Sprintf("grappler-%s.target", group)
+}
+
+// ServiceName returns the name of the unit of a service of a group
+func ServiceName(group, service string) string {
+	return fmt.Sprintf("grappler-%s-%s.service", group, service)
+}
+
+// GroupUnits returns a service unit per service of a group and a target
+// that pulls them all in, in start order
+func GroupUnits(group string, services []ServiceSpec) []Unit {
+	target := TargetName(group)
+	var units []Unit
+	var wants []string
+
+	for _, svc := range services {
+		name := ServiceName(group, svc.Name)
+		wants = append(wants, name)
+
+		var b strings.Builder
+		fmt.Fprintln(&b, "# Generated by grappler; regenerate with 'grappler systemd install'")
+		fmt.Fprintln(&b, "[Unit]")
+		fmt.Fprintf(&b, "Description=grappler %s %s\n", group, svc.Name)
+		fmt.Fprintf(&b, "PartOf=%s\n", target)
+		for _, after := range svc.After {
+			dep := ServiceName(group, after)
+			fmt.Fprintf(&b, "Wants=%s\n", dep)
+			fmt.Fprintf(&b, "After=%s\n", dep)
+		}
+		fmt.Fprintln(&b)
+
+		fmt.Fprintln(&b, "[Service]")
+		fmt.Fprintln(&b, "Type=simple")
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", svc.Dir)
+
+		keys := make([]string, 0, len(svc.Env))
+		for key := range svc.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "Environment=%s\n", QuoteEnv(key, svc.Env[key]))
+		}
+
+		writeExec(&b, "ExecStartPre", svc.ExecStartPre)
+		writeExec(&b, "ExecStart", [][]string{svc.Argv})
+		writeExec(&b, "ExecStartPost", svc.ExecStartPost)
+		writeExec(&b, "ExecStop", svc.ExecStop)
+		writeExec(&b, "ExecStopPost", svc.ExecStopPost)
+
+		if svc.LogPath != "" {
+			fmt.Fprintf(&b, "StandardOutput=append:%s\n", svc.LogPath)
+			fmt.Fprintf(&b, "StandardError=append:%s\n", svc.LogPath)
+		}
+		fmt.Fprintln(&b, "Restart=on-failure")
+		fmt.Fprintln(&b)
+
+		fmt.Fprintln(&b, "[Install]")
+		fmt.Fprintf(&b, "WantedBy=%s\n", target)
+
+		units = append(units, Unit{Name: name, Content: b.String()})
+	}
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "# Generated by grappler; regenerate with 'grappler systemd install'")
+	fmt.Fprintln(&b, "[Unit]")
+	fmt.Fprintf(&b, "Description=grappler group %s\n", group)
+	if len(wants) > 0 {
+		fmt.Fprintf(&b, "Wants=%s\n", strings.Join(wants, " "))
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "[Install]")
+	fmt.Fprintln(&b, "WantedBy=default.target")
+
+	return append(units, Unit{Name: target, Content: b.String()})
+}
+
+// writeExec writes one Exec directive per command
+func writeExec(b *strings.Builder, directive string, commands [][]string) {
+	for _, argv := range commands {
+		quoted := make([]string, len(argv))
+		for i, arg := range argv {
+			quoted[i] = QuoteArg(arg)
+		}
+		fmt.Fprintf(b, "%s=%s\n", directive, strings.Join(quoted, " "))
+	}
+}
+
+// ActiveState returns the state systemd reports for a user unit, such as
+// "active", "inactive" or "failed"
+func ActiveState(unit string) string {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return "unknown"
+	}
+
+	// is-active exits non-zero for inactive units but still prints the state
+	out, _ := exec.Command("systemctl", "--user", "is-active", unit).Output()
+	if state := strings.TrimSpace(string(out)); state != "" {
+		return state
+	}
+	return "unknown"
+}
@@ -0,0 +1,56 @@
+package worktree
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	ticketPattern = regexp.MustCompile(`(?i)\b([a-z][a-z0-9]+-[0-9]+)\b`)
+	prPattern     = regexp.MustCompile(`(?i)^(?:pr|pull)[-/]?([0-9]+)$`)
+)
+
+// Branch prefixes that say what kind of change a branch is, rather than
+// whose it is
+var branchTypes = map[string]bool{
+	"feature": true, "feat": true, "fix": true, "bugfix": true, "hotfix": true,
+	"chore": true, "docs": true, "refactor": true, "release": true, "test": true,
+}
+
+// BranchMetadata derives group labels and a description from a branch name
+// such as "krish/ERE-5326-fix-login-redirect": the first path segment
+// becomes the owner (or the type, for prefixes like feature/ and fix/), a
+// ticket key becomes the ticket, pr-123 becomes the pr, and the remaining
+// words become the description.
+func BranchMetadata(branch string) (map[string]string, string) {
+	labels := make(map[string]string)
+	segments := strings.Split(branch, "/")
+
+	if len(segments) > 1 {
+		prefix := strings.ToLower(segments[0])
+		if branchTypes[prefix] {
+			labels["type"] = prefix
+		} else {
+			labels["owner"] = segments[0]
+		}
+		segments = segments[1:]
+	}
+
+	rest := strings.Join(segments, "/")
+	if match := prPattern.FindStringSubmatch(rest); match != nil {
+		labels["pr"] = match[1]
+		return labels, ""
+	}
+	if match := ticketPattern.FindStringSubmatch(rest); match != nil {
+		labels["ticket"] = strings.ToUpper(match[1])
+		rest = strings.Replace(rest, match[1], "", 1)
+	}
+
+	description := strings.Join(strings.FieldsFunc(rest, func(r rune) bool {
+		return r == '-' || r == '_' || r == '/'
+	}), " ")
+	if len(labels) == 0 {
+		labels = nil
+	}
+	return labels, description
+}
@@ -43,12 +43,10 @@ func PairWorktrees(backendWorktrees, frontendWorktrees []Worktree) map[string]*c
 					Backend: &config.Service{
 						Directory: backend.Path,
 						Branch:    backend.Branch,
-						Command:   "go run cmd/api-server/main.go",
 					},
 					Frontend: &config.Service{
 						Directory: frontend.Path,
 						Branch:    frontend.Branch,
-						Command:   "pnpm conductor:customer",
 					},
 				}
 				pairedFrontends[frontend.Path] = true
@@ -83,12 +81,10 @@ func PairWorktrees(backendWorktrees, frontendWorktrees []Worktree) map[string]*c
 			Backend: &config.Service{
 				Directory: mainBackend.Path,
 				Branch:    mainBackend.Branch,
-				Command:   "go run cmd/api-server/main.go",
 			},
 			Frontend: &config.Service{
 				Directory: mainFrontend.Path,
 				Branch:    mainFrontend.Branch,
-				Command:   "pnpm conductor:customer",
 			},
 		}
 		pairedFrontends[mainFrontend.Path] = true
@@ -120,10 +116,29 @@ func PairWorktrees(backendWorktrees, frontendWorktrees []Worktree) map[string]*c
 			Backend: &config.Service{
 				Directory: backend.Path,
 				Branch:    backend.Branch,
-				Command:   "go run cmd/api-server/main.go",
 			},
 		}
 	}
 
+	for _, group := range groups {
+		DescribeFromBranch(group)
+	}
+
 	return groups
 }
+
+// DescribeFromBranch labels and describes a group from the branch of its
+// backend, or its frontend if the backend is on the default branch
+func DescribeFromBranch(group *config.Group) {
+	for _, svc := range group.ServiceList() {
+		if svc.Service.Branch == "" || isDefaultBranch(svc.Service.Branch) {
+			continue
+		}
+		group.Labels, group.Description = BranchMetadata(svc.Service.Branch)
+		return
+	}
+}
+
+func isDefaultBranch(branch string) bool {
+	return branch == "main" || branch == "master" || branch == "develop"
+}
@@ -44,6 +44,22 @@ func GetCommonDir(repoPath string) (string, error) {
 	return strings.TrimSpace(out.String()), nil
 }
 
+// CurrentBranch returns the branch checked out in a worktree, or "" for a
+// detached HEAD
+func CurrentBranch(path string) (string, error) {
+	cmd := exec.Command("git", "branch", "--show-current")
+	cmd.Dir = path
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to read current branch: %w", err)
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
 // parseWorktreeList parses the output of `git worktree list --porcelain`
 func parseWorktreeList(output string) []Worktree {
 	var worktrees []Worktree